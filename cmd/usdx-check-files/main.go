@@ -35,7 +35,9 @@ func main() {
 			zap.String("source", song.SourceFile),
 		)
 
-		check(l, "sound", base, song.Dir, song.SoundFile)
+		check(l, "sound", base, song.Dir, song.AudioFile())
+		check(l, "vocals", base, song.Dir, song.Vocals)
+		check(l, "instrumental", base, song.Dir, song.Instrumental)
 		check(l, "cover", base, song.Dir, song.CoverPath)
 		check(l, "background", base, song.Dir, song.BackgroundPath)
 		check(l, "video", base, song.Dir, song.VideoPath)
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/Patagonicus/usdx-reader/pkg/storage/mysql"
 	"go.uber.org/zap"
@@ -40,6 +41,7 @@ func main() {
 		"Artist",
 		"Title",
 		"Genre",
+		"Language",
 		"Edition",
 		"Year",
 		"File",
@@ -59,7 +61,8 @@ func main() {
 		err = w.Write([]string{
 			song.Artist,
 			song.Title,
-			song.Genre,
+			strings.Join(song.Genres, ", "),
+			strings.Join(song.Languages, ", "),
 			song.Edition,
 			strconv.Itoa(song.Year),
 			filepath.Join(song.Dir, song.SourceFile),
@@ -23,7 +23,7 @@ func main() {
 	}
 	defer f.Close()
 
-	song, warnings, err := usdx.NewReader(l).Read(f, "", "")
+	song, warnings, err := usdx.NewReader(usdx.WithZap(l)).Read(f, "", "")
 	if err != nil {
 		l.Fatal("failed to read file",
 			zap.String("path", os.Args[1]),
@@ -33,6 +33,7 @@ func main() {
 
 	l.Info("file info",
 		zap.Any("info", song),
+		zap.Any("times", song.Times()),
 		zap.Any("warnings", warnings),
 	)
 }
@@ -39,7 +39,7 @@ func loader(l *zap.Logger, base string, paths <-chan string, songs chan<- usdx.S
 		if len(warnings) > 0 {
 			l.Warn("got warnings",
 				zap.String("path", path),
-				zap.Errors("warnings", warnings),
+				zap.Any("warnings", warnings),
 			)
 		}
 
@@ -53,8 +53,6 @@ type inserter interface {
 
 func dispatch(l *zap.Logger, inserter inserter, songs <-chan usdx.Song, wg *sync.WaitGroup) {
 	for song := range songs {
-		// TODO: fix me (encoding problems)
-		song.Notes = nil
 		err := inserter.InsertSong(song)
 		if err != nil {
 			l.Warn("error inserting song into database",
@@ -129,11 +127,11 @@ func main() {
 	wg.Wait()
 }
 
-func tryLoad(l *zap.Logger, base, dir, source string) (usdx.Song, []error, error) {
+func tryLoad(l *zap.Logger, base, dir, source string) (usdx.Song, []usdx.Diagnostic, error) {
 	file, err := os.Open(filepath.Join(base, dir, source))
 	if err != nil {
 		return usdx.Song{}, nil, err
 	}
 	defer file.Close()
-	return usdx.NewReader(l).Read(file, dir, source)
+	return usdx.NewReader(usdx.WithZap(l)).Read(file, dir, source)
 }
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Patagonicus/usdx-reader/pkg/usdx"
+	"go.uber.org/zap"
+)
+
+// songFile is a song file found below a base directory. Dir and Source are
+// built like in usdx-sql, so Dir is relative to the base directory.
+type songFile struct {
+	Path   string
+	Dir    string
+	Source string
+}
+
+// findSongs returns all .txt files below base. If base is a file, it is
+// returned on its own.
+func findSongs(l *zap.Logger, base string) ([]songFile, error) {
+	info, err := os.Stat(base)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		dir, source := filepath.Split(base)
+		return []songFile{{
+			Path:   base,
+			Dir:    dir,
+			Source: source,
+		}}, nil
+	}
+
+	var files []songFile
+	err = filepath.Walk(base, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			l.Warn("error encountered while walking directory",
+				zap.String("path", path),
+				zap.Error(err),
+			)
+			return nil
+		}
+		if info.IsDir() || strings.ToLower(filepath.Ext(path)) != ".txt" {
+			return nil
+		}
+
+		rel, err := filepath.Rel(base, path)
+		if err != nil {
+			return err
+		}
+		dir, source := filepath.Split(rel)
+		files = append(files, songFile{
+			Path:   path,
+			Dir:    dir,
+			Source: source,
+		})
+		return nil
+	})
+	return files, err
+}
+
+// Name returns the path of the song relative to the base directory, using
+// forward slashes.
+func (f songFile) Name() string {
+	return filepath.ToSlash(filepath.Join(f.Dir, f.Source))
+}
+
+// load reads a song file, returning its content along with the parsed song.
+func (f songFile) load(r usdx.Reader) ([]byte, usdx.Song, []usdx.Diagnostic, error) {
+	content, err := os.ReadFile(f.Path)
+	if err != nil {
+		return nil, usdx.Song{}, nil, err
+	}
+	song, warnings, err := r.Read(bytes.NewReader(content), f.Dir, f.Source)
+	return content, song, warnings, err
+}
+
+// replace atomically replaces the song file with content.
+func (f songFile) replace(content []byte) error {
+	info, err := os.Stat(f.Path)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.Path), ".usdx-")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), info.Mode())
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), f.Path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// lines decodes file content and splits it into lines without line endings.
+func lines(content []byte, enc usdx.Encoding) []string {
+	s, err := enc.Decode(string(bytes.TrimPrefix(content, []byte{0xef, 0xbb, 0xbf})))
+	if err != nil {
+		s = string(content)
+	}
+	lines := usdx.SplitLines(strings.TrimPrefix(s, "\ufeff"))
+	if lines[len(lines)-1] == "" {
+		// a final line ending does not start another line
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/Patagonicus/usdx-reader/pkg/diff"
+	"github.com/Patagonicus/usdx-reader/pkg/usdx"
+	"go.uber.org/zap"
+)
+
+var lineEndings = map[string]string{
+	"keep": "",
+	"crlf": "\r\n",
+	"lf":   "\n",
+}
+
+func format(l *zap.Logger, args []string) error {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	list := flags.Bool("l", false, "list files whose formatting differs")
+	showDiff := flags.Bool("d", false, "print diffs instead of the formatted songs")
+	write := flags.Bool("w", false, "write the formatted songs to their files")
+	eol := flags.String("eol", "keep", "line ending: crlf, lf, or keep to use the first line ending of each file")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: usdx fmt [-l] [-d] [-w] [-eol ENDING] <file or directory>...\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	lineEnding, ok := lineEndings[*eol]
+	if !ok {
+		return fmt.Errorf("unknown line ending '%v'", *eol)
+	}
+
+	reader := usdx.NewReader()
+	failed := false
+	for _, base := range flags.Args() {
+		files, err := findSongs(l, base)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			content, song, formatted, err := formatFile(reader, file, lineEnding)
+			if err != nil {
+				l.Error("failed to format song",
+					zap.String("path", file.Path),
+					zap.Error(err),
+				)
+				failed = true
+				continue
+			}
+
+			changed := !bytes.Equal(content, formatted)
+			if *list && changed {
+				fmt.Println(file.Path)
+			}
+			if *showDiff && changed {
+				// both are in the encoding the song was read with
+				enc := song.SourceEncoding()
+				fmt.Print(diff.Unified(file.Path+".orig", file.Path, lines(content, enc), lines(formatted, enc)))
+			}
+			if *write && changed {
+				if err := file.replace(formatted); err != nil {
+					l.Error("failed to write song",
+						zap.String("path", file.Path),
+						zap.Error(err),
+					)
+					failed = true
+				}
+			}
+			if !*list && !*showDiff && !*write {
+				os.Stdout.Write(formatted)
+			}
+		}
+	}
+	if failed {
+		return errors.New("some songs could not be formatted")
+	}
+	return nil
+}
+
+// formatFile returns the content of a song file, the song, and the song written
+// in the canonical form. Tags are written in a fixed order, numbers and notes
+// the way the writer formats them. Lines that USDX skips, like comments or
+// invalid notes, are dropped. The encoding, #ENCODING tag and byte order mark
+// are kept. Formatting is refused if the formatted file is read as a different
+// song.
+func formatFile(reader usdx.Reader, file songFile, lineEnding string) ([]byte, usdx.Song, []byte, error) {
+	content, song, _, err := file.load(reader)
+	if err != nil {
+		return nil, song, nil, err
+	}
+	formatted, err := reader.Format(song, usdx.Writer{
+		Encoding:   song.SourceEncoding(),
+		BOM:        song.BOM,
+		LineEnding: lineEnding,
+	})
+	return content, song, formatted, err
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Patagonicus/usdx-reader/pkg/diff"
+	"github.com/Patagonicus/usdx-reader/pkg/lint"
+	"github.com/Patagonicus/usdx-reader/pkg/usdx"
+	"go.uber.org/zap"
+)
+
+// fileResult holds the problems found in a song file. Problems found while
+// reading the file use the diagnostic code as rule.
+type fileResult struct {
+	Name     string
+	Problems []lint.Problem
+}
+
+var formats = map[string]func(w io.Writer, linter *lint.Linter, results []fileResult) error{
+	"text":  writeText,
+	"json":  writeJSON,
+	"sarif": writeSARIF,
+	"junit": writeJUnit,
+}
+
+func lintSongs(l *zap.Logger, args []string) error {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	format := flags.String("format", "text", "output format: text, json, sarif or junit")
+	output := flags.String("o", "", "write the report to a file instead of stdout")
+	disable := flags.String("disable", "", "comma separated IDs of rules to disable")
+	failOn := flags.String("fail-on", "error", "fail if a problem of this severity or worse is found: info, warning or error")
+	fix := flags.Bool("fix", false, "fix problems where possible and print a diff of the changes to stderr")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: usdx lint [-fix] [-format FORMAT] [-o FILE] [-disable RULES] [-fail-on SEVERITY] <file or directory>...\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	write, ok := formats[*format]
+	if !ok {
+		return fmt.Errorf("unknown format '%v'", *format)
+	}
+	threshold, err := parseSeverity(*failOn)
+	if err != nil {
+		return err
+	}
+	var disabled []string
+	if *disable != "" {
+		disabled = strings.Split(*disable, ",")
+	}
+
+	reader := usdx.NewReader(usdx.KeepSyntax())
+	linter := lint.New(lint.Disable(disabled...))
+	var results []fileResult
+	failed := 0
+	for _, base := range flags.Args() {
+		files, err := findSongs(l, base)
+		if err != nil {
+			return err
+		}
+		// the directories of songs found in a directory are relative to it
+		root := base
+		if len(files) == 1 && files[0].Path == base {
+			root = ""
+		}
+		baseLinter := lint.New(lint.Disable(disabled...), lint.WithAudioLength(lint.AudioFileLength(root)))
+		for _, file := range files {
+			if *fix {
+				err := fixFile(reader, baseLinter, file)
+				if err != nil {
+					l.Error("failed to fix song",
+						zap.String("path", file.Path),
+						zap.Error(err),
+					)
+				}
+			}
+			result, err := lintFile(reader, baseLinter, file, disabled)
+			if err != nil {
+				l.Error("failed to read song",
+					zap.String("path", file.Path),
+					zap.Error(err),
+				)
+			}
+			for _, problem := range result.Problems {
+				if problem.Severity >= threshold {
+					failed++
+				}
+			}
+			results = append(results, result)
+		}
+	}
+
+	out := os.Stdout
+	if *output != "" {
+		out, err = os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+	err = write(out, linter, results)
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("found %d problem(s) of severity %v or worse", failed, threshold)
+	}
+	return nil
+}
+
+// lintFile reads and checks a song file. If it can not be read, the error is
+// also reported as a problem, so it shows up in the report.
+func lintFile(reader usdx.Reader, linter *lint.Linter, file songFile, disabled []string) (fileResult, error) {
+	result := fileResult{
+		Name: file.Name(),
+	}
+	_, song, diagnostics, err := file.load(reader)
+	if err != nil {
+		result.Problems = append(result.Problems, lint.Problem{
+			Rule:     "read-error",
+			Severity: usdx.SeverityError,
+			Message:  err.Error(),
+		})
+		return result, err
+	}
+
+	for _, d := range diagnostics {
+		if contains(disabled, string(d.Code)) {
+			continue
+		}
+		result.Problems = append(result.Problems, lint.Problem{
+			Rule:     string(d.Code),
+			Severity: d.Severity,
+			Line:     d.Line,
+			Message:  d.Message,
+		})
+	}
+	problems, err := linter.Lint(song)
+	result.Problems = append(result.Problems, problems...)
+	sort.SliceStable(result.Problems, func(i, j int) bool {
+		return result.Problems[i].Line < result.Problems[j].Line
+	})
+	return result, err
+}
+
+// fixFile applies the fixes of the linter to a song file and prints a diff of
+// the changes to stderr, keeping stdout for the report. The song is written
+// with its syntax, so only the fixed lines change.
+func fixFile(reader usdx.Reader, linter *lint.Linter, file songFile) error {
+	content, song, _, err := file.load(reader)
+	if err != nil {
+		return err
+	}
+	fixed, applied := linter.Fix(song)
+	if len(applied) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	err = usdx.Writer{}.Write(&buf, fixed)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(content, buf.Bytes()) {
+		return nil
+	}
+	enc := song.SourceEncoding()
+	fmt.Fprint(os.Stderr, diff.Unified(file.Path+".orig", file.Path, lines(content, enc), lines(buf.Bytes(), enc)))
+	return file.replace(buf.Bytes())
+}
+
+func parseSeverity(s string) (usdx.Severity, error) {
+	for _, severity := range []usdx.Severity{usdx.SeverityInfo, usdx.SeverityWarning, usdx.SeverityError} {
+		if severity.String() == s {
+			return severity, nil
+		}
+	}
+	return 0, errors.New("unknown severity '" + s + "'")
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
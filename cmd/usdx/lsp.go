@@ -0,0 +1,28 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Patagonicus/usdx-reader/pkg/lint"
+	"github.com/Patagonicus/usdx-reader/pkg/lsp"
+	"go.uber.org/zap"
+)
+
+func languageServer(l *zap.Logger, args []string) error {
+	flags := flag.NewFlagSet("lsp", flag.ExitOnError)
+	disable := flags.String("disable", "", "comma separated IDs of rules to disable")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: usdx lsp [-disable RULES]\n\nRuns a language server on stdin and stdout.\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	var disabled []string
+	if *disable != "" {
+		disabled = strings.Split(*disable, ",")
+	}
+	return lsp.New(lint.New(lint.Disable(disabled...), lint.WithAudioLength(lint.AudioFileLength("")))).Serve(os.Stdin, os.Stdout)
+}
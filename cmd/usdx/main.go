@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"go.uber.org/zap"
+)
+
+type command func(l *zap.Logger, args []string) error
+
+var commands = map[string]command{
+	"fmt":       format,
+	"lint":      lintSongs,
+	"lsp":       languageServer,
+	"mojibake":  mojibake,
+	"normalize": normalize,
+	"upgrade":   upgrade,
+}
+
+func main() {
+	l, err := zap.NewProduction()
+	if err != nil {
+		panic(err)
+	}
+	defer l.Sync()
+
+	if len(os.Args) < 2 {
+		usage()
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+	}
+
+	err = cmd(l, os.Args[2:])
+	if err != nil {
+		l.Fatal("command failed",
+			zap.String("command", os.Args[1]),
+			zap.Error(err),
+		)
+	}
+}
+
+func usage() {
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(os.Stderr, "usage: %v <command> [arguments]\n\ncommands:\n", os.Args[0])
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %v\n", name)
+	}
+	os.Exit(2)
+}
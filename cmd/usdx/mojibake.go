@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Patagonicus/usdx-reader/pkg/usdx"
+	"go.uber.org/zap"
+)
+
+func mojibake(l *zap.Logger, args []string) error {
+	flags := flag.NewFlagSet("mojibake", flag.ExitOnError)
+	write := flags.Bool("w", false, "rewrite the affected files after confirmation")
+	yes := flags.Bool("y", false, "do not ask for confirmation")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: usdx mojibake [-w [-y]] <file or directory>...\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	type affected struct {
+		file songFile
+		song usdx.Song
+	}
+	var songs []affected
+
+	reader := usdx.NewReader(usdx.WithZap(l), usdx.KeepSyntax())
+	for _, base := range flags.Args() {
+		files, err := findSongs(l, base)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			_, song, _, err := file.load(reader)
+			if err != nil {
+				l.Error("failed to read song",
+					zap.String("path", file.Path),
+					zap.Error(err),
+				)
+				continue
+			}
+
+			fixed, fixes := song.FixMojibake()
+			if len(fixes) == 0 {
+				continue
+			}
+			fmt.Println(file.Path)
+			for _, fix := range fixes {
+				fmt.Printf("  %v: %q -> %q\n", fix.Field, fix.Original, fix.Fixed)
+			}
+			songs = append(songs, affected{file, fixed})
+		}
+	}
+
+	if !*write || len(songs) == 0 {
+		return nil
+	}
+	if !*yes && !confirm(fmt.Sprintf("Rewrite %v files?", len(songs))) {
+		return nil
+	}
+
+	failed := false
+	for _, s := range songs {
+		var buf bytes.Buffer
+		err := usdx.Writer{}.Write(&buf, s.song)
+		if err == nil {
+			err = s.file.replace(buf.Bytes())
+		}
+		if err != nil {
+			l.Error("failed to write song",
+				zap.String("path", s.file.Path),
+				zap.Error(err),
+			)
+			failed = true
+		}
+	}
+	if failed {
+		return errors.New("some songs could not be written")
+	}
+	return nil
+}
+
+// stdin is where confirm reads answers from.
+var stdin io.Reader = os.Stdin
+
+// confirm asks a yes/no question on the terminal.
+func confirm(question string) bool {
+	fmt.Printf("%v [y/N] ", question)
+	answer, err := bufio.NewReader(stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
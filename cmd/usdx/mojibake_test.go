@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestMojibake(t *testing.T) {
+	const (
+		broken = "#TITLE:MÃ¶tley\r\n#ARTIST:b\r\n#BPM:100\r\n: 0 2 0  a\r\nE\r\n"
+		fixed  = "#TITLE:Mötley\r\n#ARTIST:b\r\n#BPM:100\r\n: 0 2 0  a\r\nE\r\n"
+		clean  = "#TITLE:a\n#ARTIST:b\n#BPM:100\n: 0 2 0 a\nE\n"
+	)
+	for _, tc := range []struct {
+		name   string
+		args   []string
+		answer string
+		want   string
+	}{
+		{name: "report only", args: nil, want: broken},
+		{name: "write", args: []string{"-w", "-y"}, want: fixed},
+		{name: "confirmed", args: []string{"-w"}, answer: "y\n", want: fixed},
+		{name: "declined", args: []string{"-w"}, answer: "n\n", want: broken},
+		{name: "no answer", args: []string{"-w"}, answer: "", want: broken},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "a", "song.txt")
+			other := filepath.Join(dir, "b", "song.txt")
+			writeFile(t, path, broken)
+			writeFile(t, other, clean)
+
+			stdin = strings.NewReader(tc.answer)
+			defer func() {
+				stdin = os.Stdin
+			}()
+			if err := mojibake(zap.NewNop(), append(tc.args, dir)); err != nil {
+				t.Fatalf("error fixing mojibake: %v", err)
+			}
+			if got := readFile(t, path); got != tc.want {
+				t.Errorf("expected\n%q\ngot\n%q", tc.want, got)
+			}
+			if got := readFile(t, other); got != clean {
+				t.Errorf("expected the song without mojibake to be unchanged, got\n%q", got)
+			}
+		})
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
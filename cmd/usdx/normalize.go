@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"unicode/utf8"
+
+	"github.com/Patagonicus/usdx-reader/pkg/encoding"
+	"github.com/Patagonicus/usdx-reader/pkg/usdx"
+	"go.uber.org/zap"
+)
+
+func normalize(l *zap.Logger, args []string) error {
+	flags := flag.NewFlagSet("normalize", flag.ExitOnError)
+	dryRun := flags.Bool("n", false, "only print the report, do not write any files")
+	bom := flags.String("bom", "keep", "UTF-8 byte order mark: add, remove, or keep to write one if the file had a byte order mark")
+	keepTag := flags.Bool("tag", false, "set #ENCODING to UTF8 instead of removing it")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: usdx normalize [-n] [-bom BOM] [-tag] <file or directory>...\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if *bom != "keep" && *bom != "add" && *bom != "remove" {
+		return fmt.Errorf("unknown byte order mark option '%v'", *bom)
+	}
+
+	report := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	defer report.Flush()
+	fmt.Fprintln(report, "FILE\tSOURCE\tCONFIDENCE\tRESULT")
+
+	reader := usdx.NewReader(usdx.WithZap(l), usdx.KeepSyntax())
+	failed := false
+	for _, base := range flags.Args() {
+		files, err := findSongs(l, base)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			content, song, _, err := file.load(reader)
+			if err != nil {
+				fmt.Fprintf(report, "%v\t\t\terror: %v\n", file.Path, err)
+				failed = true
+				continue
+			}
+
+			source, confidence := describeSource(song)
+			normalized, err := toUTF8(reader, content, song, *bom, *keepTag)
+			var result string
+			switch {
+			case err != nil:
+				result = "refused: " + err.Error()
+				failed = true
+			case bytes.Equal(content, normalized):
+				result = "unchanged"
+			case *dryRun:
+				result = "would convert"
+			default:
+				result = "converted"
+				err = file.replace(normalized)
+				if err != nil {
+					result = "error: " + err.Error()
+					failed = true
+				}
+			}
+			fmt.Fprintf(report, "%v\t%v\t%v\t%v\n", file.Path, source, confidence, result)
+		}
+	}
+	if failed {
+		return errors.New("some songs could not be converted")
+	}
+	return nil
+}
+
+// describeSource returns the name of the encoding the song was read with and
+// how confident the detection was, if there was one.
+func describeSource(song usdx.Song) (string, string) {
+	source := song.SourceEncoding().Name()
+	switch {
+	case song.Detection != nil && song.Detection.BOM:
+		return source + " (BOM)", "1.00"
+	case song.BOM:
+		return source + " (BOM)", "-"
+	case song.Detection != nil && source == song.Detection.Encoding.Name():
+		return source, fmt.Sprintf("%.2f", song.Detection.Confidence)
+	}
+	return source + " (#ENCODING)", "-"
+}
+
+// toUTF8 converts the content of a song file to UTF-8, keeping everything but
+// the #ENCODING tag as it is. The song must have been read with
+// usdx.KeepSyntax. An existing byte order mark is kept unless bom is "remove".
+// It fails if decoding the file loses characters or if the converted file
+// does not describe the same song.
+func toUTF8(reader usdx.Reader, content []byte, song usdx.Song, bom string, keepTag bool) ([]byte, error) {
+	if song.Syntax == nil {
+		return nil, errors.New("song was read without its syntax")
+	}
+	source := song.SourceEncoding()
+	body := content
+	hadBOM := false
+	switch {
+	case song.BOM:
+		body = bytes.TrimPrefix(body, []byte{0xef, 0xbb, 0xbf})
+		hadBOM = true
+	case song.Detection != nil && song.Detection.BOM:
+		body = body[2:]
+		hadBOM = true
+	}
+
+	text, err := source.Decode(string(body))
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := source.Encode(text)
+	if err != nil || encoded != string(body) || strings.ContainsRune(text, utf8.RuneError) {
+		return nil, fmt.Errorf("decoding as %v loses characters", source.Name())
+	}
+
+	var out strings.Builder
+	if bom == "add" || bom == "keep" && hadBOM {
+		out.WriteString("\ufeff")
+	}
+	for _, line := range song.Syntax.Lines {
+		if line.Kind == usdx.TagLine && line.Tag == "ENCODING" {
+			if !keepTag {
+				continue
+			}
+			line.Text = line.Text[:strings.IndexRune(line.Text, ':')+1] + encoding.UTF8.Name()
+		}
+		out.WriteString(line.Text)
+		out.WriteString(line.Ending)
+	}
+
+	converted := []byte(out.String())
+	again, _, err := reader.Read(bytes.NewReader(converted), song.Dir, song.SourceFile)
+	if err != nil {
+		return nil, err
+	}
+	if !usdx.SameSong(song, again) {
+		return nil, errors.New("converted file does not describe the same song")
+	}
+	return converted, nil
+}
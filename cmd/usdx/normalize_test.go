@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Patagonicus/usdx-reader/pkg/encoding"
+	"github.com/Patagonicus/usdx-reader/pkg/usdx"
+)
+
+func TestToUTF8(t *testing.T) {
+	cp1252 := func(s string) string {
+		encoded, err := encoding.CP1252.Encode(s)
+		if err != nil {
+			t.Fatalf("error encoding %q: %v", s, err)
+		}
+		return encoded
+	}
+	const notes = "#BPM:100\n: 0 2 0 ä\nE\n"
+	for _, tc := range []struct {
+		name    string
+		content string
+		bom     string
+		keepTag bool
+		want    string
+	}{
+		{
+			name:    "encoding tag",
+			content: cp1252("#TITLE:Mötley\n#ARTIST:b\n#ENCODING:CP1252\n" + notes),
+			bom:     "keep",
+			want:    "#TITLE:Mötley\n#ARTIST:b\n" + notes,
+		},
+		{
+			name:    "encoding tag after a blank line",
+			content: cp1252("#TITLE:Mötley\r\n#ARTIST:b\r\n\r\n#encoding:CP1252\r\n" + notes),
+			bom:     "keep",
+			want:    "#TITLE:Mötley\r\n#ARTIST:b\r\n\r\n" + notes,
+		},
+		{
+			name:    "keep the tag",
+			content: cp1252("#TITLE:Mötley\n#ARTIST:b\n\n#encoding:CP1252\n" + notes),
+			bom:     "keep",
+			keepTag: true,
+			want:    "#TITLE:Mötley\n#ARTIST:b\n\n#encoding:UTF8\n" + notes,
+		},
+		{
+			name:    "add a byte order mark",
+			content: cp1252("#TITLE:Mötley\n#ARTIST:b\n" + notes),
+			bom:     "add",
+			want:    "\ufeff#TITLE:Mötley\n#ARTIST:b\n" + notes,
+		},
+		{
+			name:    "keep a byte order mark",
+			content: "\ufeff#TITLE:Mötley\n#ARTIST:b\n" + notes,
+			bom:     "keep",
+			want:    "\ufeff#TITLE:Mötley\n#ARTIST:b\n" + notes,
+		},
+		{
+			name:    "remove a byte order mark",
+			content: "\ufeff#TITLE:Mötley\n#ARTIST:b\n" + notes,
+			bom:     "remove",
+			want:    "#TITLE:Mötley\n#ARTIST:b\n" + notes,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			reader := usdx.NewReader(usdx.KeepSyntax())
+			song, _, err := reader.Read(bytes.NewReader([]byte(tc.content)), "", "song.txt")
+			if err != nil {
+				t.Fatalf("error reading song: %v", err)
+			}
+			got, err := toUTF8(reader, []byte(tc.content), song, tc.bom, tc.keepTag)
+			if err != nil {
+				t.Fatalf("error converting song: %v", err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("expected\n%q\ngot\n%q", tc.want, got)
+			}
+		})
+	}
+}
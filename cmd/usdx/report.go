@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/Patagonicus/usdx-reader/pkg/lint"
+	"github.com/Patagonicus/usdx-reader/pkg/usdx"
+)
+
+// writeText writes one line per problem, in the format used by compilers, so
+// that editors can jump to the lines.
+func writeText(w io.Writer, linter *lint.Linter, results []fileResult) error {
+	for _, result := range results {
+		for _, p := range result.Problems {
+			if _, err := fmt.Fprintln(w, formatProblem(result.Name, p)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func formatProblem(name string, p lint.Problem) string {
+	if p.Line == 0 {
+		return fmt.Sprintf("%v: %v: %v [%v]", name, p.Severity, p.Message, p.Rule)
+	}
+	return fmt.Sprintf("%v:%d: %v: %v [%v]", name, p.Line, p.Severity, p.Message, p.Rule)
+}
+
+type jsonFile struct {
+	Path     string        `json:"path"`
+	Problems []jsonProblem `json:"problems"`
+}
+
+type jsonProblem struct {
+	Rule     string        `json:"rule"`
+	Severity usdx.Severity `json:"severity"`
+	Line     int           `json:"line,omitempty"`
+	Message  string        `json:"message"`
+}
+
+// writeJSON writes a list of all checked files with their problems.
+func writeJSON(w io.Writer, linter *lint.Linter, results []fileResult) error {
+	files := make([]jsonFile, len(results))
+	for i, result := range results {
+		problems := make([]jsonProblem, len(result.Problems))
+		for j, p := range result.Problems {
+			problems[j] = jsonProblem(p)
+		}
+		files[i] = jsonFile{
+			Path:     result.Name,
+			Problems: problems,
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(files)
+}
+
+// SARIF 2.1.0, only the parts needed to report problems in lines of files.
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	ShortDescription     *sarifMessage      `json:"shortDescription,omitempty"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+func sarifLevel(s usdx.Severity) string {
+	switch s {
+	case usdx.SeverityError:
+		return "error"
+	case usdx.SeverityWarning:
+		return "warning"
+	}
+	return "note"
+}
+
+// writeSARIF writes the problems as a SARIF log. Paths are relative to the
+// base directory, which should be the root of the repository for code hosts
+// to annotate the files.
+func writeSARIF(w io.Writer, linter *lint.Linter, results []fileResult) error {
+	run := sarifRun{
+		Tool: sarifTool{
+			Driver: sarifDriver{
+				Name: "usdx lint",
+			},
+		},
+		Results: []sarifResult{},
+	}
+	rules := make(map[string]bool)
+	for _, rule := range linter.Rules() {
+		if !linter.Enabled(rule.ID) {
+			continue
+		}
+		rules[rule.ID] = true
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+			ID:                   rule.ID,
+			ShortDescription:     &sarifMessage{Text: rule.Description},
+			DefaultConfiguration: sarifConfiguration{Level: sarifLevel(rule.Severity)},
+		})
+	}
+
+	for _, result := range results {
+		for _, p := range result.Problems {
+			// problems found while reading use diagnostic codes, which are
+			// not rules of the linter
+			if !rules[p.Rule] {
+				rules[p.Rule] = true
+				run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+					ID:                   p.Rule,
+					DefaultConfiguration: sarifConfiguration{Level: sarifLevel(p.Severity)},
+				})
+			}
+
+			location := sarifPhysicalLocation{
+				// names are relative paths, which must be escaped to be URIs
+				ArtifactLocation: sarifArtifactLocation{URI: (&url.URL{Path: result.Name}).String()},
+			}
+			if p.Line > 0 {
+				location.Region = &sarifRegion{StartLine: p.Line}
+			}
+			run.Results = append(run.Results, sarifResult{
+				RuleID:    p.Rule,
+				Level:     sarifLevel(p.Severity),
+				Message:   sarifMessage{Text: p.Message},
+				Locations: []sarifLocation{{PhysicalLocation: location}},
+			})
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs:    []sarifRun{run},
+	})
+}
+
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// writeJUnit writes a test case for each file, which fails if a warning or an
+// error was found in it. The failure lists the problems of all severities.
+func writeJUnit(w io.Writer, linter *lint.Linter, results []fileResult) error {
+	suite := junitSuite{
+		Name:  "usdx lint",
+		Tests: len(results),
+	}
+	for _, result := range results {
+		c := junitCase{
+			Name:      result.Name,
+			ClassName: "usdx lint",
+		}
+		worst := usdx.SeverityInfo
+		var text strings.Builder
+		for _, p := range result.Problems {
+			if p.Severity > worst {
+				worst = p.Severity
+			}
+			text.WriteString(formatProblem(result.Name, p) + "\n")
+		}
+		if worst > usdx.SeverityInfo {
+			suite.Failures++
+			c.Failure = &junitFailure{
+				Message: fmt.Sprintf("%d problem(s) found", len(result.Problems)),
+				Type:    worst.String(),
+				Text:    text.String(),
+			}
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitSuites{Suites: []junitSuite{suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
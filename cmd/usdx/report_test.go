@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Patagonicus/usdx-reader/pkg/lint"
+	"github.com/Patagonicus/usdx-reader/pkg/usdx"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+func TestReports(t *testing.T) {
+	var disabled []string
+	for _, rule := range lint.DefaultRules {
+		if rule.ID != "note-order" && rule.ID != "tag-whitespace" {
+			disabled = append(disabled, rule.ID)
+		}
+	}
+	linter := lint.New(lint.Disable(disabled...))
+	results := []fileResult{
+		{
+			Name: "a/song.txt",
+			Problems: []lint.Problem{
+				{Rule: "tag-whitespace", Severity: usdx.SeverityInfo, Line: 1, Message: "value of tag 'TITLE' ends with whitespace"},
+				{Rule: string(usdx.DuplicateTag), Severity: usdx.SeverityWarning, Line: 3, Message: "duplicate tag 'ARTIST'"},
+				{Rule: "note-order", Severity: usdx.SeverityError, Line: 7, Message: "note at beat 2 starts before the previous note at beat 4"},
+			},
+		},
+		{
+			Name: "b/song.txt",
+		},
+		{
+			Name: "c/song <&>.txt",
+			Problems: []lint.Problem{
+				{Rule: "tag-whitespace", Severity: usdx.SeverityInfo, Line: 2, Message: "value of tag 'ARTIST' ends with whitespace"},
+			},
+		},
+	}
+
+	for _, tc := range []struct {
+		golden string
+		write  func(w io.Writer, linter *lint.Linter, results []fileResult) error
+	}{
+		{"report.txt", writeText},
+		{"report.json", writeJSON},
+		{"report.sarif", writeSARIF},
+		{"report.xml", writeJUnit},
+	} {
+		t.Run(tc.golden, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tc.write(&buf, linter, results); err != nil {
+				t.Fatalf("error writing report: %v", err)
+			}
+			path := filepath.Join("testdata", tc.golden)
+			if *update {
+				if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != string(want) {
+				t.Errorf("report differs from %v, got\n%v", path, got)
+			}
+		})
+	}
+}
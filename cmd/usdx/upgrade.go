@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/Patagonicus/usdx-reader/pkg/diff"
+	"github.com/Patagonicus/usdx-reader/pkg/encoding"
+	"github.com/Patagonicus/usdx-reader/pkg/usdx"
+	"go.uber.org/zap"
+)
+
+func upgrade(l *zap.Logger, args []string) error {
+	flags := flag.NewFlagSet("upgrade", flag.ExitOnError)
+	to := flags.String("to", usdx.LatestVersion.String(), "format version to convert to, or 'legacy'")
+	dryRun := flags.Bool("n", false, "print a diff of the changes instead of writing them")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: usdx upgrade [-n] [--to VERSION] <file or directory>...\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	version := usdx.Legacy
+	if *to != "legacy" {
+		var err error
+		version, err = usdx.ParseVersion(*to)
+		if err != nil {
+			return err
+		}
+	}
+
+	reader := usdx.NewReader(usdx.WithZap(l), usdx.KeepSyntax())
+	failed := false
+	for _, base := range flags.Args() {
+		files, err := findSongs(l, base)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			l := l.With(zap.String("path", file.Path))
+			err := convertFile(reader, file, version, *dryRun)
+			if err != nil {
+				l.Error("failed to convert song",
+					zap.Error(err),
+				)
+				failed = true
+			}
+		}
+	}
+	if failed {
+		return errors.New("some songs could not be converted")
+	}
+	return nil
+}
+
+func convertFile(reader usdx.Reader, file songFile, version usdx.Version, dryRun bool) error {
+	content, song, _, err := file.load(reader)
+	if err != nil {
+		return err
+	}
+	converted, err := song.Convert(version)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	err = usdx.Writer{}.Write(&buf, converted)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(content, buf.Bytes()) {
+		return nil
+	}
+
+	if dryRun {
+		fmt.Print(diff.Unified(file.Path+".orig", file.Path, lines(content, song.SourceEncoding()), lines(buf.Bytes(), encoding.UTF8)))
+		return nil
+	}
+	return file.replace(buf.Bytes())
+}
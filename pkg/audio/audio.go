@@ -0,0 +1,281 @@
+// Package audio determines the length of audio files without decoding them.
+// MP3 and Ogg (Vorbis and Opus) files are supported.
+package audio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// ErrUnsupported is returned for files that are neither MP3 nor Ogg.
+var ErrUnsupported = errors.New("unsupported audio format")
+
+// FileLength returns the length of the audio file at path.
+func FileLength(path string) (time.Duration, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return Length(f)
+}
+
+// Length returns the length of an MP3 or Ogg file. The format is detected
+// from the content.
+func Length(r io.ReadSeeker) (time.Duration, error) {
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return 0, ErrUnsupported
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	switch {
+	case string(magic) == "OggS":
+		return oggLength(r)
+	case string(magic[:3]) == "ID3", magic[0] == 0xff && magic[1]&0xe0 == 0xe0:
+		return mp3Length(r)
+	}
+	return 0, ErrUnsupported
+}
+
+// bitrates in kbit/s by MPEG version and layer, indexed by the bitrate bits
+var (
+	bitratesV1L1 = [16]int{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448}
+	bitratesV1L2 = [16]int{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384}
+	bitratesV1L3 = [16]int{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320}
+	bitratesV2L1 = [16]int{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256}
+	bitratesV2L2 = [16]int{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160}
+)
+
+// frame is the header of an MPEG audio frame.
+type frame struct {
+	mpeg1      bool
+	mono       bool
+	length     int
+	samples    int
+	sampleRate int
+}
+
+// parseFrame parses the four bytes of a frame header.
+func parseFrame(h []byte) (frame, bool) {
+	var f frame
+	if h[0] != 0xff || h[1]&0xe0 != 0xe0 {
+		return f, false
+	}
+	version := h[1] >> 3 & 3
+	layer := h[1] >> 1 & 3
+	bitrateIndex := h[2] >> 4
+	rateIndex := h[2] >> 2 & 3
+	padding := int(h[2] >> 1 & 1)
+	if version == 1 || layer == 0 || bitrateIndex == 0 || bitrateIndex == 15 || rateIndex == 3 {
+		return f, false
+	}
+	f.mpeg1 = version == 3
+	f.mono = h[3]>>6 == 3
+
+	f.sampleRate = [3]int{44100, 48000, 32000}[rateIndex]
+	switch version {
+	case 2:
+		f.sampleRate /= 2
+	case 0:
+		f.sampleRate /= 4
+	}
+
+	var bitrate int
+	switch {
+	case f.mpeg1 && layer == 3:
+		bitrate = bitratesV1L1[bitrateIndex]
+	case f.mpeg1 && layer == 2:
+		bitrate = bitratesV1L2[bitrateIndex]
+	case f.mpeg1:
+		bitrate = bitratesV1L3[bitrateIndex]
+	case layer == 3:
+		bitrate = bitratesV2L1[bitrateIndex]
+	default:
+		bitrate = bitratesV2L2[bitrateIndex]
+	}
+	bitrate *= 1000
+
+	switch {
+	case layer == 3:
+		f.samples = 384
+		f.length = (12*bitrate/f.sampleRate + padding) * 4
+	case layer == 2 || f.mpeg1:
+		f.samples = 1152
+		f.length = 144*bitrate/f.sampleRate + padding
+	default:
+		f.samples = 576
+		f.length = 72*bitrate/f.sampleRate + padding
+	}
+	return f, true
+}
+
+// xingOffset returns the offset of a Xing or Info header in a frame, which
+// follows the side information.
+func (f frame) xingOffset() int {
+	switch {
+	case f.mpeg1 && !f.mono:
+		return 4 + 32
+	case f.mpeg1 || !f.mono:
+		return 4 + 17
+	}
+	return 4 + 9
+}
+
+func (f frame) duration(frames int64) time.Duration {
+	return time.Duration(frames * int64(f.samples) * int64(time.Second) / int64(f.sampleRate))
+}
+
+// mp3Length skips an ID3v2 tag and takes the number of frames from a Xing or
+// VBRI header. Files without one are read frame by frame.
+func mp3Length(r io.Reader) (time.Duration, error) {
+	br := bufio.NewReader(r)
+	if id3, err := br.Peek(10); err == nil && string(id3[:3]) == "ID3" {
+		size := int(id3[6])<<21 | int(id3[7])<<14 | int(id3[8])<<7 | int(id3[9])
+		if id3[5]&0x10 != 0 {
+			// footer
+			size += 10
+		}
+		if _, err := br.Discard(10 + size); err != nil {
+			return 0, fmt.Errorf("invalid ID3 tag: %v", err)
+		}
+	}
+
+	header, err := br.Peek(4)
+	if err != nil {
+		return 0, fmt.Errorf("no MPEG audio frame found: %v", err)
+	}
+	first, ok := parseFrame(header)
+	if !ok {
+		return 0, errors.New("no MPEG audio frame found")
+	}
+	if data, _ := br.Peek(first.length); len(data) == first.length {
+		if frames, ok := vbrFrames(first, data); ok {
+			return first.duration(frames), nil
+		}
+	}
+
+	var samples int64
+	for {
+		header, err := br.Peek(4)
+		if err != nil {
+			break
+		}
+		f, ok := parseFrame(header)
+		if !ok {
+			// e.g. an ID3v1 tag at the end
+			break
+		}
+		if _, err := br.Discard(f.length); err != nil {
+			break
+		}
+		samples += int64(f.samples) * int64(first.sampleRate) / int64(f.sampleRate)
+	}
+	if samples == 0 {
+		return 0, errors.New("no complete MPEG audio frame found")
+	}
+	return time.Duration(samples * int64(time.Second) / int64(first.sampleRate)), nil
+}
+
+// vbrFrames returns the number of frames given in a Xing, Info or VBRI header
+// in the first frame.
+func vbrFrames(f frame, data []byte) (int64, bool) {
+	if offset := f.xingOffset(); len(data) >= offset+12 {
+		tag := string(data[offset : offset+4])
+		flags := binary.BigEndian.Uint32(data[offset+4:])
+		if (tag == "Xing" || tag == "Info") && flags&1 != 0 {
+			return int64(binary.BigEndian.Uint32(data[offset+8:])), true
+		}
+	}
+	if offset := 4 + 32; len(data) >= offset+18 && string(data[offset:offset+4]) == "VBRI" {
+		return int64(binary.BigEndian.Uint32(data[offset+14:])), true
+	}
+	return 0, false
+}
+
+// oggPage is the part of an Ogg page header that is needed.
+type oggPage struct {
+	granule int64
+	serial  uint32
+}
+
+// parseOggPage parses the page header at the start of data.
+func parseOggPage(data []byte) (oggPage, bool) {
+	var p oggPage
+	if len(data) < 27 || string(data[:4]) != "OggS" || data[4] != 0 {
+		return p, false
+	}
+	p.granule = int64(binary.LittleEndian.Uint64(data[6:]))
+	p.serial = binary.LittleEndian.Uint32(data[14:])
+	return p, true
+}
+
+// oggTail is how much of the end of an Ogg file is searched for the last page.
+// Pages are at most 65307 bytes long.
+const oggTail = 65307 + 27
+
+// oggLength divides the granule position of the last page of the first
+// stream by its sample rate. Vorbis and Opus streams are supported.
+func oggLength(r io.ReadSeeker) (time.Duration, error) {
+	head := make([]byte, 27+255+19)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return 0, err
+	}
+	head = head[:n]
+	first, ok := parseOggPage(head)
+	if !ok || len(head) < 27+int(head[26]) {
+		return 0, errors.New("invalid Ogg page")
+	}
+	packet := head[27+int(head[26]):]
+
+	var rate, skip int64
+	switch {
+	case len(packet) >= 16 && string(packet[:7]) == "\x01vorbis":
+		rate = int64(binary.LittleEndian.Uint32(packet[12:]))
+	case len(packet) >= 19 && string(packet[:8]) == "OpusHead":
+		// the granule position of Opus is always at 48 kHz
+		rate = 48000
+		skip = int64(binary.LittleEndian.Uint16(packet[10:]))
+	default:
+		return 0, ErrUnsupported
+	}
+	if rate == 0 {
+		return 0, errors.New("invalid sample rate 0")
+	}
+
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	start := size - oggTail
+	if start < 0 {
+		start = 0
+	}
+	if _, err := r.Seek(start, io.SeekStart); err != nil {
+		return 0, err
+	}
+	tail, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+
+	granule := int64(-1)
+	for i := bytes.LastIndex(tail, []byte("OggS")); i >= 0; i = bytes.LastIndex(tail[:i], []byte("OggS")) {
+		if p, ok := parseOggPage(tail[i:]); ok && p.serial == first.serial && p.granule >= 0 {
+			granule = p.granule
+			break
+		}
+	}
+	if granule < skip {
+		return 0, errors.New("no Ogg page with a granule position found")
+	}
+	return time.Duration((granule - skip) * int64(time.Second) / rate), nil
+}
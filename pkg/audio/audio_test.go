@@ -0,0 +1,104 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// mp3Frames returns n frames of MPEG 1 Layer III at 128 kbit/s and 44.1 kHz.
+func mp3Frames(n int) []byte {
+	frame := make([]byte, 417)
+	copy(frame, []byte{0xff, 0xfb, 0x90, 0x00})
+	return bytes.Repeat(frame, n)
+}
+
+// oggPageData returns an Ogg page with a single packet.
+func oggPageData(granule int64, serial uint32, packet []byte) []byte {
+	header := make([]byte, 27)
+	copy(header, "OggS")
+	binary.LittleEndian.PutUint64(header[6:], uint64(granule))
+	binary.LittleEndian.PutUint32(header[14:], serial)
+	header[26] = 1
+	return append(append(header, byte(len(packet))), packet...)
+}
+
+func vorbisHeader(rate uint32) []byte {
+	packet := make([]byte, 30)
+	copy(packet, "\x01vorbis")
+	binary.LittleEndian.PutUint32(packet[12:], rate)
+	return packet
+}
+
+func opusHeader(preSkip uint16) []byte {
+	packet := make([]byte, 19)
+	copy(packet, "OpusHead")
+	binary.LittleEndian.PutUint16(packet[10:], preSkip)
+	binary.LittleEndian.PutUint32(packet[12:], 44100)
+	return packet
+}
+
+func concat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func TestLength(t *testing.T) {
+	xing := mp3Frames(1)
+	copy(xing[36:], "Xing\x00\x00\x00\x01\x00\x00\x01\x00")
+
+	for _, tc := range []struct {
+		name string
+		data []byte
+		want time.Duration
+	}{
+		{name: "mp3", data: mp3Frames(100), want: 100 * 1152 * time.Second / 44100},
+		{name: "mp3 with id3", data: concat([]byte("ID3\x04\x00\x00\x00\x00\x00\x05abcde"), mp3Frames(10)), want: 10 * 1152 * time.Second / 44100},
+		{name: "mp3 with id3v1", data: concat(mp3Frames(10), []byte("TAG"), make([]byte, 125)), want: 10 * 1152 * time.Second / 44100},
+		{name: "mp3 xing", data: concat(xing, mp3Frames(2)), want: 256 * 1152 * time.Second / 44100},
+		{
+			name: "vorbis",
+			data: concat(
+				oggPageData(0, 1, vorbisHeader(44100)),
+				oggPageData(44100, 1, []byte("audio")),
+				oggPageData(3*44100, 1, []byte("audio")),
+			),
+			want: 3 * time.Second,
+		},
+		{
+			name: "opus",
+			data: concat(
+				oggPageData(0, 7, opusHeader(312)),
+				oggPageData(2*48000+312, 7, []byte("audio")),
+			),
+			want: 2 * time.Second,
+		},
+		{
+			name: "other stream",
+			data: concat(
+				oggPageData(0, 1, vorbisHeader(8000)),
+				oggPageData(8000, 1, []byte("audio")),
+				oggPageData(99999, 2, []byte("other")),
+			),
+			want: time.Second,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Length(bytes.NewReader(tc.data))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLengthUnsupported(t *testing.T) {
+	for _, data := range [][]byte{nil, []byte("RIFF\x00\x00\x00\x00WAVE"), []byte("fLaC")} {
+		if _, err := Length(bytes.NewReader(data)); err != ErrUnsupported {
+			t.Errorf("%q: got %v, want ErrUnsupported", data, err)
+		}
+	}
+}
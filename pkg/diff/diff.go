@@ -0,0 +1,178 @@
+// Package diff creates line based diffs in the unified format.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+const context = 3
+
+// Op is the kind of an edit.
+type Op int
+
+const (
+	Equal Op = iota
+	Insert
+	Remove
+)
+
+// Edit is a single step in turning one list of lines into another.
+type Edit struct {
+	Op   Op
+	Line string
+}
+
+// Unified returns a unified diff turning a into b. The lines must not contain
+// line endings. The result is empty if both are equal.
+func Unified(fromName, toName string, a, b []string) string {
+	edits := Compute(a, b)
+
+	var hunks []string
+	for start := 0; start < len(edits); {
+		for start < len(edits) && edits[start].Op == Equal {
+			start++
+		}
+		if start == len(edits) {
+			break
+		}
+
+		// extend the hunk until there are more than 2*context equal lines
+		end := start
+		for i := start; i < len(edits); i++ {
+			if edits[i].Op != Equal {
+				end = i + 1
+			} else if i-end >= 2*context {
+				break
+			}
+		}
+
+		from := start - context
+		if from < 0 {
+			from = 0
+		}
+		to := end + context
+		if to > len(edits) {
+			to = len(edits)
+		}
+		hunks = append(hunks, hunk(edits, from, to))
+		start = to
+	}
+
+	if len(hunks) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("--- %v\n+++ %v\n", fromName, toName) + strings.Join(hunks, "")
+}
+
+func hunk(edits []Edit, from, to int) string {
+	// line numbers of the first line in a and b
+	aLine, bLine := 1, 1
+	for _, e := range edits[:from] {
+		if e.Op != Insert {
+			aLine++
+		}
+		if e.Op != Remove {
+			bLine++
+		}
+	}
+
+	var b strings.Builder
+	aCount, bCount := 0, 0
+	for _, e := range edits[from:to] {
+		switch e.Op {
+		case Equal:
+			aCount++
+			bCount++
+			b.WriteString(" " + e.Line + "\n")
+		case Remove:
+			aCount++
+			b.WriteString("-" + e.Line + "\n")
+		case Insert:
+			bCount++
+			b.WriteString("+" + e.Line + "\n")
+		}
+	}
+	if aCount == 0 {
+		aLine--
+	}
+	if bCount == 0 {
+		bLine--
+	}
+	return fmt.Sprintf("@@ -%v +%v @@\n", lineRange(aLine, aCount), lineRange(bLine, bCount)) + b.String()
+}
+
+func lineRange(start, count int) string {
+	if count == 1 {
+		return fmt.Sprint(start)
+	}
+	return fmt.Sprintf("%v,%v", start, count)
+}
+
+// Compute returns the edits turning a into b, using the longest common
+// subsequence of the lines that differ after removing the common prefix and
+// suffix.
+func Compute(a, b []string) []Edit {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var edits []Edit
+	for _, line := range a[:prefix] {
+		edits = append(edits, Edit{Equal, line})
+	}
+	edits = append(edits, lcs(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		edits = append(edits, Edit{Equal, line})
+	}
+	return edits
+}
+
+func lcs(a, b []string) []Edit {
+	// lengths[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:]
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else if lengths[i+1][j] >= lengths[i][j+1] {
+				lengths[i][j] = lengths[i+1][j]
+			} else {
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+
+	var edits []Edit
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			edits = append(edits, Edit{Equal, a[i]})
+			i++
+			j++
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			edits = append(edits, Edit{Remove, a[i]})
+			i++
+		default:
+			edits = append(edits, Edit{Insert, b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		edits = append(edits, Edit{Remove, a[i]})
+	}
+	for ; j < len(b); j++ {
+		edits = append(edits, Edit{Insert, b[j]})
+	}
+	return edits
+}
@@ -0,0 +1,77 @@
+package diff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompute(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		a, b string
+		want []Edit
+	}{
+		{name: "equal", a: "a b", b: "a b", want: []Edit{{Equal, "a"}, {Equal, "b"}}},
+		{name: "replace", a: "a b c", b: "a x c", want: []Edit{{Equal, "a"}, {Remove, "b"}, {Insert, "x"}, {Equal, "c"}}},
+		{name: "insert", a: "a c", b: "a b c", want: []Edit{{Equal, "a"}, {Insert, "b"}, {Equal, "c"}}},
+		{name: "remove", a: "a b c", b: "a c", want: []Edit{{Equal, "a"}, {Remove, "b"}, {Equal, "c"}}},
+		{name: "move", a: "a b c d", b: "b c a d", want: []Edit{{Remove, "a"}, {Equal, "b"}, {Equal, "c"}, {Insert, "a"}, {Equal, "d"}}},
+		{name: "from empty", a: "", b: "a", want: []Edit{{Insert, "a"}}},
+		{name: "to empty", a: "a", b: "", want: []Edit{{Remove, "a"}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Compute(strings.Fields(tc.a), strings.Fields(tc.b)); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+// The expected diffs are the output of diff -u.
+func TestUnified(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{name: "equal", a: "1 2 3", b: "1 2 3", want: ""},
+		{
+			name: "context",
+			a:    "1 2 3 4 5 6 7 8 9 10",
+			b:    "1 2 3 4 X 6 7 8 9 10",
+			want: "@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+X\n 6\n 7\n 8\n",
+		},
+		{
+			name: "separate hunks",
+			a:    "1 2 3 4 5 6 7 8 9 10 11 12 13 14 15 16",
+			b:    "X 2 3 4 5 6 7 8 9 10 11 12 13 14 15 Y",
+			want: "@@ -1,4 +1,4 @@\n-1\n+X\n 2\n 3\n 4\n@@ -13,4 +13,4 @@\n 13\n 14\n 15\n-16\n+Y\n",
+		},
+		{
+			name: "joined hunks",
+			a:    "1 2 3 4 5 6 7 8 9",
+			b:    "X 2 3 4 5 6 7 Y 9",
+			want: "@@ -1,9 +1,9 @@\n-1\n+X\n 2\n 3\n 4\n 5\n 6\n 7\n-8\n+Y\n 9\n",
+		},
+		{
+			name: "insert at start",
+			a:    "1 2 3 4 5",
+			b:    "X 1 2 3 4 5",
+			want: "@@ -1,3 +1,4 @@\n+X\n 1\n 2\n 3\n",
+		},
+		{name: "remove all", a: "1 2", b: "", want: "@@ -1,2 +0,0 @@\n-1\n-2\n"},
+		{name: "from empty", a: "", b: "1 2", want: "@@ -0,0 +1,2 @@\n+1\n+2\n"},
+		{name: "single lines", a: "1", b: "2", want: "@@ -1 +1 @@\n-1\n+2\n"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			want := tc.want
+			if want != "" {
+				want = "--- a\n+++ b\n" + want
+			}
+			if got := Unified("a", "b", strings.Fields(tc.a), strings.Fields(tc.b)); got != want {
+				t.Errorf("got\n%v\nwant\n%v", got, want)
+			}
+		})
+	}
+}
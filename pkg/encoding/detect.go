@@ -0,0 +1,258 @@
+package encoding
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Detection is the result of detecting the encoding of a file.
+type Detection struct {
+	Encoding Decoder
+	// Confidence ranges from 0 for a guess to 1 for a certain result.
+	Confidence float64
+	// BOM is set if the encoding was detected from a byte order mark.
+	BOM bool
+	// Ambiguous lists encodings that scored as well as Encoding, but produce
+	// a different text.
+	Ambiguous []Decoder
+}
+
+// candidate is a single byte encoding that can be detected, along with letters
+// that are common in texts using it.
+type candidate struct {
+	Decoder
+	common string
+}
+
+var (
+	westernLetters = "äöüßÄÖÜéèêëàâáçñíìîïóòôúùûåæøÅÆØÉÈÀÇÑ"
+	easternLetters = "ąćęłńśźżĄĆĘŁŃŚŹŻčďěňřšťůžČĎĚŇŘŠŤŮŽőűŐŰáéíóúýÁÉÍÓÚÝäöüßÄÖÜ"
+	typography     = "„“”‘’–—…"
+
+	candidates = []candidate{
+		{CP1252, westernLetters + typography + "€"},
+		{ISO8859_15, westernLetters + "€œŒšŠžŽŸ"},
+		{CP1250, easternLetters + typography},
+		{CP1251, typography},
+	}
+)
+
+// Detect guesses the encoding of a whole file. Byte order marks for UTF-8 and
+// UTF-16 are recognized, as is UTF-16 without one. Valid UTF-8 is preferred
+// over single byte encodings. Otherwise CP1252, ISO-8859-15, CP1250 and CP1251
+// are scored by how plausible the characters they produce are.
+func (d UTF8DetectingDecoder) Detect(data []byte) Detection {
+	if bytes.HasPrefix(data, []byte{0xef, 0xbb, 0xbf}) {
+		return Detection{Encoding: UTF8, Confidence: 1, BOM: true}
+	}
+
+	if detection, ok := DetectUTF16(data); ok {
+		return detection
+	}
+
+	if isUTF8(string(data)) {
+		if isASCII(data) {
+			return Detection{Encoding: UTF8, Confidence: 1}
+		}
+		return Detection{Encoding: UTF8, Confidence: 0.99}
+	}
+
+	return detectSingleByte(data, d.Fallback)
+}
+
+// DetectUTF16 reports whether data is UTF-16, either from its byte order mark
+// or from the zero bytes of ASCII characters.
+func DetectUTF16(data []byte) (Detection, bool) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xff, 0xfe}):
+		return Detection{Encoding: UTF16LE, Confidence: 1, BOM: true}, true
+	case bytes.HasPrefix(data, []byte{0xfe, 0xff}):
+		return Detection{Encoding: UTF16BE, Confidence: 1, BOM: true}, true
+	}
+	enc, confidence, ok := detectUTF16(data)
+	return Detection{Encoding: enc, Confidence: confidence}, ok
+}
+
+// detectUTF16 looks for the zero bytes UTF-16 uses for ASCII characters.
+func detectUTF16(data []byte) (Decoder, float64, bool) {
+	pairs := len(data) / 2
+	if pairs < 2 {
+		return Decoder{}, 0, false
+	}
+	var even, odd int
+	for i := 0; i+1 < len(data); i += 2 {
+		if data[i] == 0 {
+			even++
+		}
+		if data[i+1] == 0 {
+			odd++
+		}
+	}
+
+	confidence := func(zeros int) float64 {
+		c := 2 * float64(zeros) / float64(pairs)
+		if c > 1 {
+			return 1
+		}
+		return c
+	}
+	switch {
+	case odd*10 > pairs*3 && even*20 < pairs:
+		return UTF16LE, confidence(odd), true
+	case even*10 > pairs*3 && odd*20 < pairs:
+		return UTF16BE, confidence(even), true
+	}
+	return Decoder{}, 0, false
+}
+
+// detectSingleByte scores the candidates and the fallback. Candidates that
+// score the same are preferred in the order of candidates, as CP1252 is the
+// most common single byte encoding of song files; the tie is reported in
+// Ambiguous. A fallback that is not a single byte encoding, like Shift-JIS,
+// can not be scored and is used if it decodes the data without errors.
+func detectSingleByte(data []byte, fallback Decoder) Detection {
+	all := candidates
+	if fallback.Decoder != nil {
+		text, err := fallback.Decoder.String(string(data))
+		if err == nil && utf8.RuneCountInString(text) != len(data) {
+			if !strings.ContainsRune(text, utf8.RuneError) {
+				return Detection{Encoding: fallback}
+			}
+		} else {
+			all = append(append([]candidate(nil), candidates...), candidate{fallback, ""})
+			for _, c := range candidates {
+				if c.Name() == fallback.Name() {
+					all[len(all)-1].common = c.common
+				}
+			}
+		}
+	}
+
+	type result struct {
+		candidate
+		text  string
+		score int
+	}
+	var results []result
+	for _, c := range all {
+		text, err := c.Decoder.Decoder.String(string(data))
+		if err != nil || utf8.RuneCountInString(text) != len(data) {
+			continue
+		}
+		results = append(results, result{c, text, score(data, []rune(text), c.common)})
+	}
+	if len(results) == 0 {
+		return Detection{Encoding: fallback}
+	}
+
+	best := results[0]
+	for _, r := range results[1:] {
+		if r.score > best.score {
+			best = r
+		}
+	}
+	if best.score <= 0 {
+		// without any evidence, do what USDX does
+		for _, r := range results {
+			if r.Name() == fallback.Name() && r.score == best.score {
+				best = r
+			}
+		}
+	}
+
+	// only encodings that produce a different text compete with the best one
+	second, competing := 0, false
+	var ambiguous []Decoder
+	for _, r := range results {
+		if r.text == best.text {
+			continue
+		}
+		if !competing || r.score > second {
+			second, competing = r.score, true
+		}
+		if r.score == best.score && !containsDecoder(ambiguous, r.Decoder) {
+			ambiguous = append(ambiguous, r.Decoder)
+		}
+	}
+
+	confidence := 1.0
+	switch {
+	case best.score <= 0:
+		confidence = 0
+	case competing && second > 0:
+		confidence = float64(best.score-second) / float64(best.score)
+	}
+	return Detection{
+		Encoding:   best.Decoder,
+		Confidence: confidence,
+		Ambiguous:  ambiguous,
+	}
+}
+
+func containsDecoder(decoders []Decoder, d Decoder) bool {
+	for _, other := range decoders {
+		if other.Name() == d.Name() {
+			return true
+		}
+	}
+	return false
+}
+
+// score rates the characters decoded from the non-ASCII bytes of data. Runes
+// must hold exactly one rune for every byte.
+//
+// Besides the letters common for an encoding, the context is used: accented
+// Latin letters are usually surrounded by ASCII letters, while Cyrillic
+// letters appear next to each other.
+func score(data []byte, runes []rune, common string) int {
+	is := func(i int, f func(r rune) bool) bool {
+		return i >= 0 && i < len(runes) && f(runes[i])
+	}
+	isASCIILetter := func(r rune) bool {
+		return r < 0x80 && unicode.IsLetter(r)
+	}
+	isCyrillic := func(r rune) bool {
+		return unicode.Is(unicode.Cyrillic, r)
+	}
+
+	total := 0
+	for i, b := range data {
+		if b < 0x80 {
+			continue
+		}
+		r := runes[i]
+		switch {
+		case r == unicode.ReplacementChar || unicode.IsControl(r):
+			total -= 10
+		case isCyrillic(r):
+			if is(i-1, isCyrillic) || is(i+1, isCyrillic) {
+				total += 3
+			}
+		case strings.ContainsRune(common, r):
+			total += 3
+		case unicode.IsLetter(r):
+			total++
+		}
+
+		if unicode.Is(unicode.Latin, r) {
+			switch {
+			case is(i-1, isASCIILetter) || is(i+1, isASCIILetter):
+				total++
+			case is(i-1, unicode.IsLetter) && is(i+1, unicode.IsLetter):
+				total -= 2
+			}
+		}
+	}
+	return total
+}
+
+func isASCII(data []byte) bool {
+	for _, b := range data {
+		if b >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
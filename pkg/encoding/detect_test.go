@@ -0,0 +1,72 @@
+package encoding
+
+import (
+	"bytes"
+	"testing"
+)
+
+func encode(t *testing.T, d Decoder, s string) []byte {
+	t.Helper()
+	b, err := d.Encoder.String(s)
+	if err != nil {
+		t.Fatalf("error encoding %q as %v: %v", s, d.Name(), err)
+	}
+	return []byte(b)
+}
+
+func TestDetect(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		data      []byte
+		fallback  Decoder
+		want      string
+		bom       bool
+		ambiguous []string
+	}{
+		{name: "ascii", data: []byte("#TITLE:Song\n"), want: "UTF8"},
+		{name: "utf-8", data: []byte("#TITLE:Café\n"), want: "UTF8"},
+		{name: "utf-8 bom", data: []byte("\xef\xbb\xbf#TITLE:Song\n"), want: "UTF8", bom: true},
+		{name: "utf-16le bom", data: append([]byte{0xff, 0xfe}, encode(t, UTF16LE, "#TITLE:Song\n")...), want: "UTF-16LE", bom: true},
+		{name: "utf-16be", data: encode(t, UTF16BE, "#TITLE:Song\n"), want: "UTF-16BE"},
+		{name: "cp1252", data: encode(t, CP1252, "#TITLE:Über Straße\n#ARTIST:Café Élan\n"), want: "CP1252"},
+		{name: "cp1250", data: encode(t, CP1250, "#TITLE:Zażółć gęślą jaźń\n"), want: "CP1250"},
+		{name: "cp1251", data: encode(t, CP1251, "#TITLE:Калинка\n"), want: "CP1251"},
+		{name: "tie prefers cp1252", data: encode(t, CP1252, "#TITLE:España\n"), want: "CP1252", ambiguous: []string{"CP1250"}},
+		{name: "shift-jis fallback", data: encode(t, ShiftJIS, "#TITLE:こんにちは世界\n"), fallback: ShiftJIS, want: "SHIFT-JIS"},
+		{name: "shift-jis fallback with western text", data: encode(t, CP1252, "#TITLE:Café au lait\n"), fallback: ShiftJIS, want: "CP1252"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fallback := tc.fallback
+			if fallback.Decoder == nil {
+				fallback = CP1250
+			}
+			got := NewAuto(fallback).Detect(tc.data)
+			if got.Encoding.Name() != tc.want {
+				t.Errorf("got encoding %v, want %v", got.Encoding.Name(), tc.want)
+			}
+			if got.BOM != tc.bom {
+				t.Errorf("got BOM %v, want %v", got.BOM, tc.bom)
+			}
+			var ambiguous []string
+			for _, d := range got.Ambiguous {
+				ambiguous = append(ambiguous, d.Name())
+			}
+			if len(ambiguous) != len(tc.ambiguous) || len(ambiguous) > 0 && ambiguous[0] != tc.ambiguous[0] {
+				t.Errorf("got ambiguous %v, want %v", ambiguous, tc.ambiguous)
+			}
+		})
+	}
+}
+
+// Scoring assumes one rune per byte, which multi byte fallbacks do not
+// produce. This used to panic.
+func TestDetectMultiByteFallback(t *testing.T) {
+	inputs := [][]byte{
+		encode(t, ShiftJIS, "#TITLE:こんにちは世界のみなさん\n: 0 1 0 あ\n"),
+		[]byte("#TITLE:\x82\xa0\xe9\n"),
+		bytes.Repeat([]byte{0x81, 0x41, 0xe9}, 20),
+	}
+	for _, data := range inputs {
+		NewAuto(ShiftJIS).Detect(data)
+	}
+}
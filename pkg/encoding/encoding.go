@@ -3,6 +3,7 @@ package encoding
 import (
 	enc "golang.org/x/text/encoding"
 	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
 	"golang.org/x/text/encoding/unicode"
 )
 
@@ -10,24 +11,69 @@ var (
 	UTF8 = Decoder{
 		DecoderName: "UTF8",
 		Decoder:     unicode.UTF8.NewDecoder(),
+		Encoder:     unicode.UTF8.NewEncoder(),
 	}
 	CP1250 = Decoder{
 		DecoderName: "CP1250",
 		Decoder:     charmap.Windows1250.NewDecoder(),
+		Encoder:     charmap.Windows1250.NewEncoder(),
 	}
 	CP1252 = Decoder{
 		DecoderName: "CP1252",
 		Decoder:     charmap.Windows1252.NewDecoder(),
+		Encoder:     charmap.Windows1252.NewEncoder(),
 	}
-	Auto = UTF8DetectingDecoder{
-		UTF8:     unicode.UTF8.NewDecoder(),
-		Fallback: charmap.Windows1250.NewDecoder(),
+	CP1251 = Decoder{
+		DecoderName: "CP1251",
+		Decoder:     charmap.Windows1251.NewDecoder(),
+		Encoder:     charmap.Windows1251.NewEncoder(),
+	}
+	ISO8859_1 = Decoder{
+		DecoderName: "ISO-8859-1",
+		Decoder:     charmap.ISO8859_1.NewDecoder(),
+		Encoder:     charmap.ISO8859_1.NewEncoder(),
+	}
+	ISO8859_2 = Decoder{
+		DecoderName: "ISO-8859-2",
+		Decoder:     charmap.ISO8859_2.NewDecoder(),
+		Encoder:     charmap.ISO8859_2.NewEncoder(),
+	}
+	ISO8859_15 = Decoder{
+		DecoderName: "ISO-8859-15",
+		Decoder:     charmap.ISO8859_15.NewDecoder(),
+		Encoder:     charmap.ISO8859_15.NewEncoder(),
 	}
+	ShiftJIS = Decoder{
+		DecoderName: "SHIFT-JIS",
+		Decoder:     japanese.ShiftJIS.NewDecoder(),
+		Encoder:     japanese.ShiftJIS.NewEncoder(),
+	}
+	UTF16LE = Decoder{
+		DecoderName: "UTF-16LE",
+		Decoder:     unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM).NewDecoder(),
+		Encoder:     unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM).NewEncoder(),
+	}
+	UTF16BE = Decoder{
+		DecoderName: "UTF-16BE",
+		Decoder:     unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM).NewDecoder(),
+		Encoder:     unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM).NewEncoder(),
+	}
+	Auto = NewAuto(CP1250)
 )
 
+// NewAuto returns a decoder that uses UTF-8 for valid UTF-8 values and the
+// fallback for all others.
+func NewAuto(fallback Decoder) UTF8DetectingDecoder {
+	return UTF8DetectingDecoder{
+		UTF8:     unicode.UTF8.NewDecoder(),
+		Fallback: fallback,
+	}
+}
+
 type Decoder struct {
 	DecoderName string
 	Decoder     *enc.Decoder
+	Encoder     *enc.Encoder
 }
 
 func (d Decoder) Name() string {
@@ -38,9 +84,13 @@ func (d Decoder) Decode(s string) (string, error) {
 	return d.Decoder.String(s)
 }
 
+func (d Decoder) Encode(s string) (string, error) {
+	return d.Encoder.String(s)
+}
+
 type UTF8DetectingDecoder struct {
 	UTF8     *enc.Decoder
-	Fallback *enc.Decoder
+	Fallback Decoder
 }
 
 func (d UTF8DetectingDecoder) Name() string {
@@ -51,7 +101,13 @@ func (d UTF8DetectingDecoder) Decode(s string) (string, error) {
 	if isUTF8(s) {
 		return d.UTF8.String(s)
 	}
-	return d.Fallback.String(s)
+	return d.Fallback.Decode(s)
+}
+
+// Encode always encodes to UTF-8, as that is the only encoding that can be
+// detected reliably.
+func (d UTF8DetectingDecoder) Encode(s string) (string, error) {
+	return UTF8.Encode(s)
 }
 
 func isUTF8(s string) bool {
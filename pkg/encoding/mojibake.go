@@ -0,0 +1,80 @@
+package encoding
+
+import "unicode/utf8"
+
+// Mojibake describes text that was encoded as UTF-8 and then decoded with a
+// single byte encoding, possibly more than once.
+type Mojibake struct {
+	Original string
+	Fixed    string
+	// Encoding is the encoding the text was wrongly decoded with last.
+	Encoding Decoder
+	// Rounds is the number of wrong conversions that were undone.
+	Rounds int
+}
+
+// maxRounds limits how many wrong conversions are undone.
+const maxRounds = 3
+
+var mojibakeEncodings = []Decoder{CP1252, CP1250, ISO8859_15, CP1251}
+
+// DetectMojibake checks whether s looks like mojibake, e.g. "MÃ¶tley CrÃ¼e",
+// and returns the repaired text. Text is considered mojibake if encoding it
+// with one of CP1252, CP1250, ISO-8859-15 or CP1251 yields valid UTF-8 that
+// only contains plausible characters.
+func DetectMojibake(s string) (Mojibake, bool) {
+	m := Mojibake{
+		Original: s,
+		Fixed:    s,
+	}
+	for m.Rounds < maxRounds {
+		fixed, enc, ok := undoMojibake(m.Fixed)
+		if !ok {
+			break
+		}
+		m.Fixed = fixed
+		m.Encoding = enc
+		m.Rounds++
+	}
+	return m, m.Rounds > 0
+}
+
+func undoMojibake(s string) (string, Decoder, bool) {
+	if isASCII([]byte(s)) {
+		return "", Decoder{}, false
+	}
+
+	for _, enc := range mojibakeEncodings {
+		b, err := enc.Encoder.String(s)
+		if err != nil || !utf8.ValidString(b) {
+			continue
+		}
+		if utf8.RuneCountInString(b) >= utf8.RuneCountInString(s) {
+			// no multi byte sequences were formed
+			continue
+		}
+		if !plausible(b) {
+			continue
+		}
+		return b, enc, true
+	}
+	return "", Decoder{}, false
+}
+
+// plausible reports whether all non-ASCII characters in s are ones that
+// commonly appear in song files. This rejects text in a single byte encoding
+// that happens to be valid UTF-8, like "DÓŁ" in CP1250.
+func plausible(s string) bool {
+	for _, r := range s {
+		switch {
+		case r < 0x80:
+		case r >= 0xa0 && r <= 0x24f: // Latin-1 Supplement, Latin Extended-A and -B
+		case r >= 0x400 && r <= 0x45f: // basic Cyrillic
+		case r >= 0x2010 && r <= 0x206f: // General Punctuation
+		case r == '€':
+		default:
+			return false
+		}
+	}
+	return true
+}
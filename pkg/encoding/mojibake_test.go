@@ -0,0 +1,36 @@
+package encoding
+
+import (
+	"testing"
+)
+
+func TestDetectMojibake(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		in       string
+		want     string
+		encoding string
+		rounds   int
+	}{
+		{name: "cp1252", in: "MÃ¶tley CrÃ¼e", want: "Mötley Crüe", encoding: "CP1252", rounds: 1},
+		{name: "twice", in: "MÃƒÂ¶tley CrÃƒÂ¼e", want: "Mötley Crüe", encoding: "CP1252", rounds: 2},
+		{name: "cp1250", in: "ZaĹĽĂłĹ‚Ä‡ gÄ™Ĺ›lÄ…", want: "Zażółć gęślą", encoding: "CP1250", rounds: 1},
+		{name: "cp1251", in: "РџСЂРёРІРµС‚", want: "Привет", encoding: "CP1251", rounds: 1},
+		{name: "correct", in: "Mötley Crüe", want: "Mötley Crüe"},
+		{name: "ascii", in: "plain", want: "plain"},
+		{name: "implausible", in: "DÓŁ", want: "DÓŁ"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := DetectMojibake(tc.in)
+			if ok != (tc.rounds > 0) || got.Fixed != tc.want || got.Rounds != tc.rounds {
+				t.Fatalf("got %q, %v after %v rounds, want %q after %v rounds", got.Fixed, ok, got.Rounds, tc.want, tc.rounds)
+			}
+			if got.Original != tc.in {
+				t.Errorf("got original %q, want %q", got.Original, tc.in)
+			}
+			if ok && got.Encoding.Name() != tc.encoding {
+				t.Errorf("got encoding %v, want %v", got.Encoding.Name(), tc.encoding)
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package lint
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/Patagonicus/usdx-reader/pkg/usdx"
+)
+
+// Fix repairs a kind of problem that can be fixed mechanically.
+type Fix struct {
+	// Rule is the ID of the rule or the diagnostic code of the reader whose
+	// problems are fixed.
+	Rule string
+	// Apply changes the song and reports whether anything was changed. It must
+	// not modify data shared with other copies of the song.
+	Apply func(song *usdx.Song) bool
+}
+
+// DefaultFixes are the fixes applied by a new Linter. Fixes that change the
+// lines of the header need a song read with usdx.KeepSyntax, fixes that move
+// beats skip relative songs.
+var DefaultFixes = []Fix{
+	{"tag-whitespace", fixTagWhitespace},
+	{string(usdx.DuplicateTag), fixDuplicateTags},
+	{"note-order", fixNoteOrder},
+	{"break-inside-note", fixBreakInsideNote},
+	{"missing-end", fixMissingEnd},
+	{"syllable-space", fixSyllableSpace},
+}
+
+// Fix applies all enabled fixes to a copy of the song and returns it, along
+// with the IDs of the fixes that changed something. Line numbers in the
+// returned song still refer to the original file.
+func (l *Linter) Fix(song usdx.Song) (usdx.Song, []string) {
+	var applied []string
+	for _, fix := range l.fixes {
+		if l.disabled[fix.Rule] {
+			continue
+		}
+		if fix.Apply(&song) {
+			applied = append(applied, fix.Rule)
+		}
+	}
+	return song, applied
+}
+
+func fixTagWhitespace(song *usdx.Song) bool {
+	if song.Syntax == nil {
+		return false
+	}
+	syntax := *song.Syntax
+	lines, ok := tagLines(syntax, song.Header)
+	if !ok {
+		return false
+	}
+	syntax.Lines = append([]usdx.SyntaxLine(nil), syntax.Lines...)
+	song.Header = append([]usdx.HeaderTag(nil), song.Header...)
+
+	changed := false
+	for i, tag := range song.Header {
+		value := strings.TrimRightFunc(tag.Value, unicode.IsSpace)
+		if value == tag.Value {
+			continue
+		}
+		song.Header[i].Value = value
+		song.Header[i].Raw = strings.TrimRightFunc(tag.Raw, unicode.IsSpace)
+		line := &syntax.Lines[lines[i]]
+		line.Text = strings.TrimRightFunc(line.Text, unicode.IsSpace)
+		line.Value = value
+		changed = true
+	}
+	song.Syntax = &syntax
+	return changed
+}
+
+func fixDuplicateTags(song *usdx.Song) bool {
+	duplicates := song.DuplicateTags()
+	if song.Syntax == nil || len(duplicates) == 0 {
+		return false
+	}
+	lines, ok := tagLines(*song.Syntax, song.Header)
+	if !ok {
+		return false
+	}
+	duplicate := make(map[int]bool)
+	for _, tag := range duplicates {
+		duplicate[tag.Line] = true
+	}
+	remove := make(map[int]bool)
+	var header []usdx.HeaderTag
+	for i, tag := range song.Header {
+		if duplicate[tag.Line] {
+			remove[lines[i]] = true
+		} else {
+			header = append(header, tag)
+		}
+	}
+
+	syntax := *song.Syntax
+	syntax.Lines = nil
+	for i, line := range song.Syntax.Lines {
+		if !remove[i] {
+			syntax.Lines = append(syntax.Lines, line)
+		}
+	}
+	song.Syntax = &syntax
+	song.Header = header
+	return true
+}
+
+// tagLines returns the indexes of the tag lines of the syntax. Every header tag
+// is read from a tag line and the fixes remove them together, so the n-th tag
+// line belongs to header[n]. Line numbers cannot be used because earlier fixes
+// may have removed lines.
+func tagLines(syntax usdx.Syntax, header []usdx.HeaderTag) ([]int, bool) {
+	var lines []int
+	for i, line := range syntax.Lines {
+		if line.Kind == usdx.TagLine {
+			if len(lines) == len(header) || header[len(lines)].Tag != line.Tag {
+				return nil, false
+			}
+			lines = append(lines, i)
+		}
+	}
+	return lines, len(lines) == len(header)
+}
+
+// fixNoteOrder sorts the notes of each track by their start. Lines keep their
+// number of notes, so notes can move to another line.
+func fixNoteOrder(song *usdx.Song) bool {
+	if song.Relative {
+		return false
+	}
+	copyTracks(song)
+	changed := false
+	for _, track := range song.Tracks {
+		var notes []usdx.Note
+		for _, line := range track.Lines {
+			notes = append(notes, line.Notes...)
+		}
+		less := func(i, j int) bool {
+			return notes[i].Start < notes[j].Start
+		}
+		if sort.SliceIsSorted(notes, less) {
+			continue
+		}
+		sort.SliceStable(notes, less)
+		for _, line := range track.Lines {
+			copy(line.Notes, notes)
+			notes = notes[len(line.Notes):]
+		}
+		changed = true
+	}
+	return changed
+}
+
+// fixBreakInsideNote moves line breaks that are inside the last note of their
+// line to its end, and line breaks after the first note of the next line to
+// that note.
+func fixBreakInsideNote(song *usdx.Song) bool {
+	if song.Relative {
+		return false
+	}
+	copyTracks(song)
+	changed := false
+	for _, track := range song.Tracks {
+		for i, line := range track.Lines {
+			if line.Break == nil || len(line.Notes) == 0 {
+				continue
+			}
+			last := line.Notes[len(line.Notes)-1]
+			beat := line.Break.Beat
+			if end := last.Start + last.Length; beat < end {
+				beat = end
+			}
+			if i+1 < len(track.Lines) && len(track.Lines[i+1].Notes) > 0 {
+				if next := track.Lines[i+1].Notes[0]; beat > next.Start {
+					beat = next.Start
+				}
+			}
+			if beat != line.Break.Beat {
+				line.Break.Beat = beat
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+func fixMissingEnd(song *usdx.Song) bool {
+	if song.Terminated {
+		return false
+	}
+	song.Terminated = true
+	return true
+}
+
+// fixSyllableSpace moves spaces to the side of the syllables that most
+// syllables of the song use. A space moved to a syllable that already has one
+// there is dropped.
+func fixSyllableSpace(song *usdx.Song) bool {
+	trailing, ok := spaceSide(*song)
+	if !ok {
+		return false
+	}
+	copyTracks(song)
+	changed := false
+	for _, track := range song.Tracks {
+		for _, line := range track.Lines {
+			notes := line.Notes
+			for i := range notes {
+				switch {
+				case trailing && i > 0 && wrongSpace(notes[i].Syllable, false):
+					notes[i].Syllable = strings.TrimLeft(notes[i].Syllable, " ")
+					notes[i-1].Syllable = strings.TrimRight(notes[i-1].Syllable, " ") + " "
+				case !trailing && i+1 < len(notes) && wrongSpace(notes[i].Syllable, true):
+					notes[i].Syllable = strings.TrimRight(notes[i].Syllable, " ")
+					notes[i+1].Syllable = " " + strings.TrimLeft(notes[i+1].Syllable, " ")
+				default:
+					continue
+				}
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// copyTracks replaces the tracks of the song with a deep copy, so they can be
+// changed without affecting other copies of the song.
+func copyTracks(song *usdx.Song) {
+	tracks := make([]usdx.Track, len(song.Tracks))
+	for i, track := range song.Tracks {
+		lines := make([]usdx.Line, len(track.Lines))
+		for j, line := range track.Lines {
+			lines[j].Notes = append([]usdx.Note(nil), line.Notes...)
+			if line.Break != nil {
+				lineBreak := *line.Break
+				lines[j].Break = &lineBreak
+			}
+		}
+		track.Lines = lines
+		tracks[i] = track
+	}
+	song.Tracks = tracks
+}
@@ -0,0 +1,147 @@
+package lint
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Patagonicus/usdx-reader/pkg/usdx"
+)
+
+func readSong(t *testing.T, text string) usdx.Song {
+	t.Helper()
+	song, _, err := usdx.NewReader(usdx.KeepSyntax()).Read(strings.NewReader(text), "", "test.txt")
+	if err != nil {
+		t.Fatalf("error reading song: %v", err)
+	}
+	return song
+}
+
+func TestFix(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		text     string
+		disabled []string
+		want     string
+		applied  []string
+	}{
+		{
+			name:    "tag whitespace",
+			text:    "#TITLE:a \n#ARTIST:b\t\n#BPM:100\n: 0 2 0 a\nE\n",
+			want:    "#TITLE:a\n#ARTIST:b\n#BPM:100\n: 0 2 0 a\nE\n",
+			applied: []string{"tag-whitespace"},
+		},
+		{
+			name:    "duplicate tags",
+			text:    "#TITLE:a\n#ARTIST:b\n#TITLE:c\n#BPM:100\n: 0 2 0 a\nE\n",
+			want:    "#ARTIST:b\n#TITLE:c\n#BPM:100\n: 0 2 0 a\nE\n",
+			applied: []string{string(usdx.DuplicateTag)},
+		},
+		{
+			name:    "duplicate tags after a blank line",
+			text:    "#TITLE:a\n\n#ARTIST:b\n#ARTIST:c\n#BPM:100\n: 0 2 0 a\nE\n",
+			want:    "#TITLE:a\n\n#ARTIST:c\n#BPM:100\n: 0 2 0 a\nE\n",
+			applied: []string{string(usdx.DuplicateTag)},
+		},
+		{
+			name:    "whitespace and duplicates",
+			text:    "#TITLE:a\n#TITLE:b\n#ARTIST:c \n#BPM:100\n: 0 2 0 a\nE\n",
+			want:    "#TITLE:b\n#ARTIST:c\n#BPM:100\n: 0 2 0 a\nE\n",
+			applied: []string{"tag-whitespace", string(usdx.DuplicateTag)},
+		},
+		{
+			name:    "note order",
+			text:    "#TITLE:a\n#ARTIST:b\n#BPM:100\n: 4 2 0 c\n: 0 2 0 a\n- 6\n: 2 2 0 b\nE\n",
+			want:    "#TITLE:a\n#ARTIST:b\n#BPM:100\n: 0 2 0 a\n: 2 2 0 b\n- 4\n: 4 2 0 c\nE\n",
+			applied: []string{"note-order", "break-inside-note"},
+		},
+		{
+			name:    "note order of a relative song",
+			text:    "#TITLE:a\n#ARTIST:b\n#BPM:100\n#RELATIVE:yes\n: 4 2 0 b\n: 0 2 0 a\n- 6 6\n: 0 2 0 c\nE\n",
+			want:    "#TITLE:a\n#ARTIST:b\n#BPM:100\n#RELATIVE:yes\n: 4 2 0 b\n: 0 2 0 a\n- 6 6\n: 0 2 0 c\nE\n",
+			applied: nil,
+		},
+		{
+			name:    "break inside the last note",
+			text:    "#TITLE:a\n#ARTIST:b\n#BPM:100\n: 0 4 0 a\n- 2\n: 6 2 0 b\nE\n",
+			want:    "#TITLE:a\n#ARTIST:b\n#BPM:100\n: 0 4 0 a\n- 4\n: 6 2 0 b\nE\n",
+			applied: []string{"break-inside-note"},
+		},
+		{
+			name:    "break after the next note",
+			text:    "#TITLE:a\n#ARTIST:b\n#BPM:100\n: 0 2 0 a\n- 8\n: 6 4 0 b\nE\n",
+			want:    "#TITLE:a\n#ARTIST:b\n#BPM:100\n: 0 2 0 a\n- 6\n: 6 4 0 b\nE\n",
+			applied: []string{"break-inside-note"},
+		},
+		{
+			name:    "missing end",
+			text:    "#TITLE:a\n#ARTIST:b\n#BPM:100\n: 0 2 0 a\n",
+			want:    "#TITLE:a\n#ARTIST:b\n#BPM:100\n: 0 2 0 a\nE\n",
+			applied: []string{"missing-end"},
+		},
+		{
+			name:    "leading space",
+			text:    "#TITLE:a\n#ARTIST:b\n#BPM:100\n: 0 2 0 a\n: 2 2 0  b\n: 4 2 0  c\n: 6 2 0 d \n: 8 2 0 e\n- 10\n: 12 2 0 f\nE\n",
+			want:    "#TITLE:a\n#ARTIST:b\n#BPM:100\n: 0 2 0 a\n: 2 2 0  b\n: 4 2 0  c\n: 6 2 0 d\n: 8 2 0  e\n- 10\n: 12 2 0 f\nE\n",
+			applied: []string{"syllable-space"},
+		},
+		{
+			name:    "trailing space",
+			text:    "#TITLE:a\n#ARTIST:b\n#BPM:100\n: 0 2 0 a \n: 2 2 0 b \n: 4 2 0  c\n: 6 2 0 d\nE\n",
+			want:    "#TITLE:a\n#ARTIST:b\n#BPM:100\n: 0 2 0 a \n: 2 2 0 b \n: 4 2 0 c\n: 6 2 0 d\nE\n",
+			applied: []string{"syllable-space"},
+		},
+		{
+			name:     "disabled",
+			text:     "#TITLE:a \n#ARTIST:b\n#BPM:100\n: 0 2 0 a\n",
+			disabled: []string{"tag-whitespace", "missing-end"},
+			want:     "#TITLE:a \n#ARTIST:b\n#BPM:100\n: 0 2 0 a\n",
+			applied:  nil,
+		},
+		{
+			name:    "nothing to fix",
+			text:    "#TITLE:a\n#ARTIST:b\n#BPM:100\n: 0 2 0 a\n- 4\n: 6 2 0 b\nE\n",
+			want:    "#TITLE:a\n#ARTIST:b\n#BPM:100\n: 0 2 0 a\n- 4\n: 6 2 0 b\nE\n",
+			applied: nil,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			song := readSong(t, tc.text)
+			fixed, applied := New(Disable(tc.disabled...)).Fix(song)
+			if !reflect.DeepEqual(applied, tc.applied) {
+				t.Errorf("expected fixes %v, got %v", tc.applied, applied)
+			}
+			var buf bytes.Buffer
+			if err := (usdx.Writer{}).Write(&buf, fixed); err != nil {
+				t.Fatalf("error writing song: %v", err)
+			}
+			if got := buf.String(); got != tc.want {
+				t.Errorf("expected\n%q\ngot\n%q", tc.want, got)
+			}
+
+			var original bytes.Buffer
+			if err := (usdx.Writer{}).Write(&original, song); err != nil {
+				t.Fatalf("error writing song: %v", err)
+			}
+			if got := original.String(); got != tc.text {
+				t.Errorf("fixing changed the original song to\n%q", got)
+			}
+		})
+	}
+}
+
+func TestFixTagWhitespaceAfterRemovedLines(t *testing.T) {
+	song := readSong(t, "#TITLE:a\n#TITLE:b\n#ARTIST:c \n#BPM:100\n: 0 2 0 a\nE\n")
+	if !fixDuplicateTags(&song) || !fixTagWhitespace(&song) {
+		t.Fatalf("expected both fixes to change the song")
+	}
+	var buf bytes.Buffer
+	if err := (usdx.Writer{}).Write(&buf, song); err != nil {
+		t.Fatalf("error writing song: %v", err)
+	}
+	want := "#TITLE:b\n#ARTIST:c\n#BPM:100\n: 0 2 0 a\nE\n"
+	if got := buf.String(); got != want {
+		t.Errorf("expected\n%q\ngot\n%q", want, got)
+	}
+}
@@ -0,0 +1,193 @@
+// Package lint checks songs for problems that do not prevent reading them,
+// but show up when singing, like overlapping notes.
+package lint
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/Patagonicus/usdx-reader/pkg/audio"
+	"github.com/Patagonicus/usdx-reader/pkg/usdx"
+)
+
+// Problem is a problem found by a rule. Line starts at 1 and is 0 if the
+// problem is not tied to a line.
+type Problem struct {
+	Rule     string
+	Severity usdx.Severity
+	Line     int
+	Message  string
+}
+
+func (p Problem) String() string {
+	if p.Line == 0 {
+		return fmt.Sprintf("%v: %v [%v]", p.Severity, p.Message, p.Rule)
+	}
+	return fmt.Sprintf("line %d: %v: %v [%v]", p.Line, p.Severity, p.Message, p.Rule)
+}
+
+// Rule checks songs for one kind of problem.
+type Rule struct {
+	// ID identifies the rule, e.g. to disable it. IDs are stable.
+	ID          string
+	Severity    usdx.Severity
+	Description string
+	Check       func(c *Context)
+}
+
+// Context is passed to the rules. It holds the song being checked and collects
+// the problems found.
+type Context struct {
+	// Song is the song being checked, made absolute if it was relative.
+	Song usdx.Song
+	// AudioLength is the length of the audio file of the song, or 0 if it is
+	// not known.
+	AudioLength time.Duration
+
+	rule     Rule
+	problems []Problem
+}
+
+// Report adds a problem found by the current rule.
+func (c *Context) Report(line int, format string, args ...interface{}) {
+	c.problems = append(c.problems, Problem{
+		Rule:     c.rule.ID,
+		Severity: c.rule.Severity,
+		Line:     line,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// Linter runs rules over songs.
+type Linter struct {
+	rules       []Rule
+	fixes       []Fix
+	disabled    map[string]bool
+	audioLength func(song usdx.Song) (time.Duration, error)
+}
+
+// Option configures a Linter.
+type Option func(l *Linter)
+
+// New creates a linter running DefaultRules and DefaultFixes.
+func New(opts ...Option) *Linter {
+	l := &Linter{
+		rules:    append([]Rule(nil), DefaultRules...),
+		fixes:    append([]Fix(nil), DefaultFixes...),
+		disabled: make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Disable turns off the rules and fixes with the given IDs.
+func Disable(ids ...string) Option {
+	return func(l *Linter) {
+		for _, id := range ids {
+			l.disabled[id] = true
+		}
+	}
+}
+
+// WithSeverity changes the severity of a rule.
+func WithSeverity(id string, severity usdx.Severity) Option {
+	return func(l *Linter) {
+		for i := range l.rules {
+			if l.rules[i].ID == id {
+				l.rules[i].Severity = severity
+			}
+		}
+	}
+}
+
+// WithRules adds rules. Rules replace earlier ones with the same ID.
+func WithRules(rules ...Rule) Option {
+	return func(l *Linter) {
+	next:
+		for _, rule := range rules {
+			for i := range l.rules {
+				if l.rules[i].ID == rule.ID {
+					l.rules[i] = rule
+					continue next
+				}
+			}
+			l.rules = append(l.rules, rule)
+		}
+	}
+}
+
+// WithFixes adds fixes, which are applied after the existing ones.
+func WithFixes(fixes ...Fix) Option {
+	return func(l *Linter) {
+		l.fixes = append(l.fixes, fixes...)
+	}
+}
+
+// WithAudioLength sets a function that determines the length of the audio
+// file of a song. Without it, rules that need the length are skipped.
+func WithAudioLength(f func(song usdx.Song) (time.Duration, error)) Option {
+	return func(l *Linter) {
+		l.audioLength = f
+	}
+}
+
+// AudioFileLength returns a function for WithAudioLength that reads the length
+// of MP3 and Ogg files. Their paths are the directory of the song joined to
+// root. Songs without an audio file, missing files and other formats have an
+// unknown length.
+func AudioFileLength(root string) func(song usdx.Song) (time.Duration, error) {
+	return func(song usdx.Song) (time.Duration, error) {
+		if song.AudioFile() == "" {
+			return 0, nil
+		}
+		length, err := audio.FileLength(filepath.Join(root, song.Dir, song.AudioFile()))
+		if os.IsNotExist(err) || err == audio.ErrUnsupported {
+			return 0, nil
+		}
+		return length, err
+	}
+}
+
+// Rules returns the rules of the linter, including disabled ones.
+func (l *Linter) Rules() []Rule {
+	return append([]Rule(nil), l.rules...)
+}
+
+// Enabled reports whether the rule with the given ID is run.
+func (l *Linter) Enabled(id string) bool {
+	return !l.disabled[id]
+}
+
+// Lint checks a song and returns the problems found, ordered by line. An
+// error is only returned if the length of the audio file could not be
+// determined; the problems found by the other rules are still returned.
+func (l *Linter) Lint(song usdx.Song) ([]Problem, error) {
+	c := &Context{
+		Song: song.ToAbsolute(),
+	}
+	var err error
+	if l.audioLength != nil {
+		c.AudioLength, err = l.audioLength(song)
+		if err != nil {
+			err = fmt.Errorf("error getting audio length: %v", err)
+		}
+	}
+
+	for _, rule := range l.rules {
+		if l.disabled[rule.ID] {
+			continue
+		}
+		c.rule = rule
+		rule.Check(c)
+	}
+
+	sort.SliceStable(c.problems, func(i, j int) bool {
+		return c.problems[i].Line < c.problems[j].Line
+	})
+	return c.problems, err
+}
@@ -0,0 +1,219 @@
+package lint
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/Patagonicus/usdx-reader/pkg/usdx"
+)
+
+// DefaultRules are the rules run by a new Linter.
+var DefaultRules = []Rule{
+	{"note-order", usdx.SeverityError, "Notes of a singer must not overlap and must be in order.", checkNoteOrder},
+	{"zero-length", usdx.SeverityWarning, "Notes must be at least one beat long.", checkZeroLength},
+	{"missing-end", usdx.SeverityWarning, "The note section should end with E.", checkMissingEnd},
+	{"break-inside-note", usdx.SeverityWarning, "Line breaks must be placed between notes.", checkBreakInsideNote},
+	{"medley-range", usdx.SeverityWarning, "#MEDLEYSTARTBEAT and #MEDLEYENDBEAT must be inside the song.", checkMedleyRange},
+	{"preview-after-end", usdx.SeverityWarning, "#PREVIEWSTART must be before the end of the song.", checkPreviewAfterEnd},
+	{"gap-after-audio", usdx.SeverityError, "#GAP must be shorter than the audio file.", checkGapAfterAudio},
+	{"empty-syllable", usdx.SeverityWarning, "Notes should have a syllable.", checkEmptySyllable},
+	{"tag-whitespace", usdx.SeverityInfo, "Tag values should not end with whitespace.", checkTagWhitespace},
+	{"syllable-space", usdx.SeverityInfo, "Spaces between words should be on the same side of the syllables throughout the song.", checkSyllableSpace},
+}
+
+func checkNoteOrder(c *Context) {
+	for _, track := range c.Song.Tracks {
+		var previous *usdx.Note
+		for _, line := range track.Lines {
+			for i := range line.Notes {
+				note := &line.Notes[i]
+				switch {
+				case previous == nil:
+				case note.Start < previous.Start:
+					c.Report(note.Line, "note at beat %d starts before the previous note at beat %d", note.Start, previous.Start)
+				case note.Start < previous.Start+previous.Length:
+					c.Report(note.Line, "note at beat %d overlaps the previous note ending at beat %d", note.Start, previous.Start+previous.Length)
+				}
+				previous = note
+			}
+		}
+	}
+}
+
+func checkZeroLength(c *Context) {
+	forEachNote(c.Song, func(note usdx.Note) {
+		if note.Length <= 0 {
+			c.Report(note.Line, "note at beat %d has a length of %d", note.Start, note.Length)
+		}
+	})
+}
+
+func checkMissingEnd(c *Context) {
+	if !c.Song.Terminated {
+		c.Report(0, "note section does not end with E")
+	}
+}
+
+func checkBreakInsideNote(c *Context) {
+	for _, track := range c.Song.Tracks {
+		for i, line := range track.Lines {
+			if line.Break == nil || len(line.Notes) == 0 {
+				continue
+			}
+			last := line.Notes[len(line.Notes)-1]
+			if end := last.Start + last.Length; line.Break.Beat < end {
+				c.Report(line.Break.Line, "line break at beat %d is inside the note ending at beat %d", line.Break.Beat, end)
+			}
+			if i+1 < len(track.Lines) && len(track.Lines[i+1].Notes) > 0 {
+				next := track.Lines[i+1].Notes[0]
+				if line.Break.Beat > next.Start {
+					c.Report(line.Break.Line, "line break at beat %d is after the next note at beat %d", line.Break.Beat, next.Start)
+				}
+			}
+		}
+	}
+}
+
+func checkMedleyRange(c *Context) {
+	song := c.Song
+	if song.MedleyStartBeat == 0 && song.MedleyEndBeat == 0 {
+		return
+	}
+	first, last, ok := noteRange(song)
+	if !ok {
+		return
+	}
+	if song.MedleyStartBeat >= song.MedleyEndBeat {
+		c.Report(tagLine(song, "MEDLEYENDBEAT"), "medley ends at beat %d, not after its start at beat %d", song.MedleyEndBeat, song.MedleyStartBeat)
+	}
+	if song.MedleyStartBeat < first || song.MedleyStartBeat > last {
+		c.Report(tagLine(song, "MEDLEYSTARTBEAT"), "medley start at beat %d is outside of the notes from beat %d to %d", song.MedleyStartBeat, first, last)
+	}
+	if song.MedleyEndBeat < first || song.MedleyEndBeat > last {
+		c.Report(tagLine(song, "MEDLEYENDBEAT"), "medley end at beat %d is outside of the notes from beat %d to %d", song.MedleyEndBeat, first, last)
+	}
+}
+
+func checkPreviewAfterEnd(c *Context) {
+	song := c.Song
+	times := song.Times()
+	if times.PreviewStart == 0 {
+		return
+	}
+	line := tagLine(song, "PREVIEWSTART")
+	if times.End > 0 && times.PreviewStart >= times.End {
+		c.Report(line, "preview starts at %v, after #END at %v", times.PreviewStart, times.End)
+	} else if _, last, ok := noteRange(song); ok && times.End == 0 {
+		if end := song.Timing().Time(float64(last)); times.PreviewStart >= end {
+			c.Report(line, "preview starts at %v, after the last note at %v", times.PreviewStart, end)
+		}
+	}
+	if c.AudioLength > 0 && times.PreviewStart >= c.AudioLength {
+		c.Report(line, "preview starts at %v, after the end of the audio file at %v", times.PreviewStart, c.AudioLength)
+	}
+}
+
+func checkGapAfterAudio(c *Context) {
+	gap := c.Song.Times().Gap
+	if c.AudioLength > 0 && gap >= c.AudioLength {
+		c.Report(tagLine(c.Song, "GAP"), "gap of %v is longer than the audio file of %v", gap, c.AudioLength)
+	}
+}
+
+func checkEmptySyllable(c *Context) {
+	forEachNote(c.Song, func(note usdx.Note) {
+		if strings.TrimSpace(note.Syllable) == "" {
+			c.Report(note.Line, "note at beat %d has no syllable", note.Start)
+		}
+	})
+}
+
+func checkTagWhitespace(c *Context) {
+	for _, tag := range c.Song.Header {
+		if strings.TrimRightFunc(tag.Value, unicode.IsSpace) != tag.Value {
+			c.Report(tag.Line, "value of tag '%v' ends with whitespace", tag.Tag)
+		}
+	}
+}
+
+func checkSyllableSpace(c *Context) {
+	trailing, ok := spaceSide(c.Song)
+	if !ok {
+		return
+	}
+	for _, track := range c.Song.Tracks {
+		for _, line := range track.Lines {
+			for i, note := range line.Notes {
+				switch {
+				case trailing && i > 0 && wrongSpace(note.Syllable, false):
+					c.Report(note.Line, "syllable '%v' starts with a space, but most syllables of the song end with one", note.Syllable)
+				case !trailing && i+1 < len(line.Notes) && wrongSpace(note.Syllable, true):
+					c.Report(note.Line, "syllable '%v' ends with a space, but most syllables of the song start with one", note.Syllable)
+				}
+			}
+		}
+	}
+}
+
+// spaceSide returns whether most syllables with a space next to them have it
+// at their end. It returns false for ok if there are as many with a space at
+// the start, as there is no convention to follow then.
+func spaceSide(song usdx.Song) (trailing, ok bool) {
+	leading, trailingCount := 0, 0
+	forEachNote(song, func(note usdx.Note) {
+		if strings.TrimSpace(note.Syllable) == "" {
+			return
+		}
+		if strings.HasPrefix(note.Syllable, " ") {
+			leading++
+		}
+		if strings.HasSuffix(note.Syllable, " ") {
+			trailingCount++
+		}
+	})
+	return trailingCount > leading, trailingCount != leading
+}
+
+// wrongSpace reports whether the syllable has a space at its end if trailing
+// is set, or at its start otherwise. Syllables without other characters are
+// left to the empty-syllable rule.
+func wrongSpace(syllable string, trailing bool) bool {
+	if strings.TrimSpace(syllable) == "" {
+		return false
+	}
+	if trailing {
+		return strings.HasSuffix(syllable, " ")
+	}
+	return strings.HasPrefix(syllable, " ")
+}
+
+func forEachNote(song usdx.Song, f func(note usdx.Note)) {
+	for _, track := range song.Tracks {
+		for _, line := range track.Lines {
+			for _, note := range line.Notes {
+				f(note)
+			}
+		}
+	}
+}
+
+// noteRange returns the first beat of the first note and the last beat of the
+// last note of all tracks.
+func noteRange(song usdx.Song) (int, int, bool) {
+	first, last, ok := 0, 0, false
+	forEachNote(song, func(note usdx.Note) {
+		if !ok || note.Start < first {
+			first = note.Start
+		}
+		if !ok || note.Start+note.Length > last {
+			last = note.Start + note.Length
+		}
+		ok = true
+	})
+	return first, last, ok
+}
+
+func tagLine(song usdx.Song, tag string) int {
+	t, _ := song.LookupTag(tag)
+	return t.Line
+}
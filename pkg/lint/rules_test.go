@@ -0,0 +1,245 @@
+package lint
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Patagonicus/usdx-reader/pkg/usdx"
+)
+
+func TestRules(t *testing.T) {
+	const header = "#TITLE:a\n#ARTIST:b\n#BPM:100\n"
+	for _, tc := range []struct {
+		name  string
+		rule  string
+		text  string
+		audio time.Duration
+		lines []int
+	}{
+		{
+			name:  "notes in order",
+			rule:  "note-order",
+			text:  header + ": 0 2 0 a\n: 2 2 0 b\n- 4\n: 4 2 0 c\nE\n",
+			lines: nil,
+		},
+		{
+			name:  "note before the previous one",
+			rule:  "note-order",
+			text:  header + ": 4 2 0 a\n: 0 2 0 b\nE\n",
+			lines: []int{5},
+		},
+		{
+			name:  "overlapping notes",
+			rule:  "note-order",
+			text:  header + ": 0 4 0 a\n: 2 2 0 b\nE\n",
+			lines: []int{5},
+		},
+		{
+			name:  "overlapping notes across a line break",
+			rule:  "note-order",
+			text:  header + ": 0 4 0 a\n- 4\n: 2 2 0 b\nE\n",
+			lines: []int{6},
+		},
+		{
+			name:  "notes of different singers",
+			rule:  "note-order",
+			text:  header + "P1\n: 0 4 0 a\nP2\n: 2 2 0 b\nE\n",
+			lines: nil,
+		},
+		{
+			name:  "overlapping notes of a relative song",
+			rule:  "note-order",
+			text:  "#TITLE:a\n#ARTIST:b\n#BPM:100\n#RELATIVE:yes\n: 0 4 0 a\n- 4 2\n: 0 2 0 b\nE\n",
+			lines: []int{7},
+		},
+		{
+			name:  "zero length",
+			rule:  "zero-length",
+			text:  header + ": 0 2 0 a\n: 2 0 0 b\nE\n",
+			lines: []int{5},
+		},
+		{
+			name:  "end",
+			rule:  "missing-end",
+			text:  header + ": 0 2 0 a\nE\n",
+			lines: nil,
+		},
+		{
+			name:  "missing end",
+			rule:  "missing-end",
+			text:  header + ": 0 2 0 a\n",
+			lines: []int{0},
+		},
+		{
+			name:  "break between notes",
+			rule:  "break-inside-note",
+			text:  header + ": 0 2 0 a\n- 2\n: 4 2 0 b\n- 6\n: 6 2 0 c\nE\n",
+			lines: nil,
+		},
+		{
+			name:  "break inside the last note",
+			rule:  "break-inside-note",
+			text:  header + ": 0 4 0 a\n- 2\n: 6 2 0 b\nE\n",
+			lines: []int{5},
+		},
+		{
+			name:  "break after the next note",
+			rule:  "break-inside-note",
+			text:  header + ": 0 2 0 a\n- 8\n: 6 4 0 b\nE\n",
+			lines: []int{5},
+		},
+		{
+			name:  "no medley",
+			rule:  "medley-range",
+			text:  header + ": 0 2 0 a\nE\n",
+			lines: nil,
+		},
+		{
+			name:  "medley inside the song",
+			rule:  "medley-range",
+			text:  header + "#MEDLEYSTARTBEAT:4\n#MEDLEYENDBEAT:8\n: 0 4 0 a\n: 4 4 0 b\nE\n",
+			lines: nil,
+		},
+		{
+			name:  "medley ending before its start",
+			rule:  "medley-range",
+			text:  header + "#MEDLEYSTARTBEAT:6\n#MEDLEYENDBEAT:2\n: 0 4 0 a\n: 4 4 0 b\nE\n",
+			lines: []int{5},
+		},
+		{
+			name:  "medley outside of the notes",
+			rule:  "medley-range",
+			text:  header + "#MEDLEYSTARTBEAT:2\n#MEDLEYENDBEAT:20\n: 4 4 0 a\n: 8 4 0 b\nE\n",
+			lines: []int{4, 5},
+		},
+		{
+			name:  "preview before the end",
+			rule:  "preview-after-end",
+			text:  header + "#PREVIEWSTART:0.5\n: 0 4 0 a\n: 4 4 0 b\nE\n",
+			audio: time.Minute,
+			lines: nil,
+		},
+		{
+			name:  "preview after #END",
+			rule:  "preview-after-end",
+			text:  header + "#END:5000\n#PREVIEWSTART:10\n: 0 4 0 a\nE\n",
+			lines: []int{5},
+		},
+		{
+			name:  "preview after the last note",
+			rule:  "preview-after-end",
+			text:  header + "#PREVIEWSTART:10\n: 0 4 0 a\n: 4 4 0 b\nE\n",
+			lines: []int{4},
+		},
+		{
+			name:  "preview after the audio file",
+			rule:  "preview-after-end",
+			text:  header + "#END:20000\n#PREVIEWSTART:10\n: 0 4 0 a\nE\n",
+			audio: 5 * time.Second,
+			lines: []int{5},
+		},
+		{
+			name:  "gap inside the audio file",
+			rule:  "gap-after-audio",
+			text:  header + "#GAP:1000\n: 0 4 0 a\nE\n",
+			audio: 5 * time.Second,
+			lines: nil,
+		},
+		{
+			name:  "gap after the audio file",
+			rule:  "gap-after-audio",
+			text:  header + "#GAP:10000\n: 0 4 0 a\nE\n",
+			audio: 5 * time.Second,
+			lines: []int{4},
+		},
+		{
+			name:  "gap with an unknown audio length",
+			rule:  "gap-after-audio",
+			text:  header + "#GAP:10000\n: 0 4 0 a\nE\n",
+			lines: nil,
+		},
+		{
+			name:  "empty syllable",
+			rule:  "empty-syllable",
+			text:  header + ": 0 2 0 a\n: 2 2 0  \nE\n",
+			lines: []int{5},
+		},
+		{
+			name:  "tag whitespace",
+			rule:  "tag-whitespace",
+			text:  "#TITLE:a \n#ARTIST:b\n#BPM:100\t\n: 0 2 0 a\nE\n",
+			lines: []int{1, 3},
+		},
+		{
+			name:  "spaces on the same side",
+			rule:  "syllable-space",
+			text:  header + ": 0 2 0 a \n: 2 2 0 b \n: 4 2 0 c\nE\n",
+			lines: nil,
+		},
+		{
+			name:  "leading space in a song with trailing spaces",
+			rule:  "syllable-space",
+			text:  header + ": 0 2 0 a \n: 2 2 0 b \n: 4 2 0  c\nE\n",
+			lines: []int{6},
+		},
+		{
+			name:  "trailing space in a song with leading spaces",
+			rule:  "syllable-space",
+			text:  header + ": 0 2 0 a\n: 2 2 0  b\n: 4 2 0  c\n: 6 2 0 d \n: 8 2 0 e\nE\n",
+			lines: []int{7},
+		},
+		{
+			name:  "as many leading as trailing spaces",
+			rule:  "syllable-space",
+			text:  header + ": 0 2 0 a \n: 2 2 0  b\nE\n",
+			lines: nil,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			l := New(WithAudioLength(func(song usdx.Song) (time.Duration, error) {
+				return tc.audio, nil
+			}))
+			problems, err := l.Lint(readSong(t, tc.text))
+			if err != nil {
+				t.Fatalf("error linting song: %v", err)
+			}
+			var lines []int
+			for _, p := range problems {
+				if p.Rule == tc.rule {
+					lines = append(lines, p.Line)
+				}
+			}
+			if !reflect.DeepEqual(lines, tc.lines) {
+				t.Errorf("expected problems on lines %v, got %v: %v", tc.lines, lines, problems)
+			}
+		})
+	}
+}
+
+func TestLinterOptions(t *testing.T) {
+	song := readSong(t, "#TITLE:a \n#ARTIST:b\n#BPM:100\n: 0 0 0 a\n")
+	custom := Rule{"title", usdx.SeverityError, "", func(c *Context) {
+		c.Report(1, "title '%v'", c.Song.Title)
+	}}
+	l := New(
+		Disable("missing-end"),
+		WithSeverity("tag-whitespace", usdx.SeverityError),
+		WithRules(custom),
+	)
+	problems, err := l.Lint(song)
+	if err != nil {
+		t.Fatalf("error linting song: %v", err)
+	}
+	want := []Problem{
+		{"tag-whitespace", usdx.SeverityError, 1, "value of tag 'TITLE' ends with whitespace"},
+		{"title", usdx.SeverityError, 1, "title 'a '"},
+		{"zero-length", usdx.SeverityWarning, 4, "note at beat 0 has a length of 0"},
+	}
+	if !reflect.DeepEqual(problems, want) {
+		t.Errorf("expected %v, got %v", want, problems)
+	}
+	if l.Enabled("missing-end") || !l.Enabled("zero-length") {
+		t.Errorf("expected only missing-end to be disabled")
+	}
+}
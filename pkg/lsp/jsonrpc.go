@@ -0,0 +1,145 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"sync"
+)
+
+// JSON-RPC error codes used by the server.
+const (
+	parseError     = -32700
+	invalidRequest = -32600
+	methodNotFound = -32601
+	invalidParams  = -32602
+	internalError  = -32603
+)
+
+// message is a JSON-RPC request or notification. Requests have an ID,
+// notifications do not.
+type message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+}
+
+// response is a JSON-RPC response. The ID is always sent and is null if the
+// ID of the request could not be read.
+type response struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Result  interface{}      `json:"result,omitempty"`
+	Error   *responseError   `json:"error,omitempty"`
+}
+
+type responseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *responseError) Error() string {
+	return e.Message
+}
+
+// conn reads and writes messages framed by a Content-Length header, as used
+// by the language server protocol.
+type conn struct {
+	in  *textproto.Reader
+	out io.Writer
+	mu  sync.Mutex
+}
+
+func newConn(in io.Reader, out io.Writer) *conn {
+	return &conn{
+		in:  textproto.NewReader(bufio.NewReader(in)),
+		out: out,
+	}
+}
+
+func (c *conn) read() (message, error) {
+	header, err := c.in.ReadMIMEHeader()
+	if err != nil {
+		return message{}, err
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || length < 0 {
+		c.skipValue()
+		return message{}, &responseError{parseError, fmt.Sprintf("invalid Content-Length '%v'", header.Get("Content-Length"))}
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(c.in.R, body); err != nil {
+		return message{}, err
+	}
+
+	var msg message
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return message{}, &responseError{parseError, err.Error()}
+	}
+	return msg, nil
+}
+
+// skipValue skips the body of a message without a valid Content-Length by
+// reading a single JSON value, so that the following messages can be read.
+func (c *conn) skipValue() {
+	dec := json.NewDecoder(c.in.R)
+	var v json.RawMessage
+	dec.Decode(&v)
+	// the decoder may have read past the value
+	c.in = textproto.NewReader(bufio.NewReader(io.MultiReader(dec.Buffered(), c.in.R)))
+}
+
+func (c *conn) write(msg interface{}) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := fmt.Fprintf(c.out, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = c.out.Write(body)
+	return err
+}
+
+func (c *conn) reply(id *json.RawMessage, result interface{}, err error) error {
+	msg := response{
+		JSONRPC: "2.0",
+		ID:      id,
+	}
+	switch e := err.(type) {
+	case nil:
+		// a successful response must have a result, even if it is null
+		raw := json.RawMessage("null")
+		if result != nil {
+			b, err := json.Marshal(result)
+			if err != nil {
+				return err
+			}
+			raw = b
+		}
+		msg.Result = raw
+	case *responseError:
+		msg.Error = e
+	default:
+		msg.Error = &responseError{internalError, err.Error()}
+	}
+	return c.write(msg)
+}
+
+func (c *conn) notify(method string, params interface{}) error {
+	b, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return c.write(message{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  b,
+	})
+}
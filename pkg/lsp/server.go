@@ -0,0 +1,328 @@
+// Package lsp implements a language server for UltraStar song files. It
+// publishes the diagnostics of the reader and the problems found by the
+// linter, shows the time of beats and the names of pitches on hover,
+// completes tags and formats songs like usdx fmt.
+package lsp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/Patagonicus/usdx-reader/pkg/encoding"
+	"github.com/Patagonicus/usdx-reader/pkg/lint"
+	"github.com/Patagonicus/usdx-reader/pkg/usdx"
+)
+
+// Server is a language server communicating over a single connection, e.g.
+// stdin and stdout. Documents are synchronized in full.
+type Server struct {
+	reader usdx.Reader
+	linter *lint.Linter
+	conn   *conn
+	// docs holds the text of the open documents by URI
+	docs     map[string]string
+	shutdown bool
+}
+
+// New creates a server that checks songs with the given linter. The options
+// configure the reader; encodings selected with #ENCODING are ignored, as the
+// editor already decoded the text.
+func New(linter *lint.Linter, opts ...usdx.Option) *Server {
+	opts = append(opts, usdx.WithEncodings(textEncoding(encoding.CP1250.Name()), textEncoding(encoding.CP1252.Name())))
+	return &Server{
+		reader: usdx.NewReader(opts...),
+		linter: linter,
+		docs:   make(map[string]string),
+	}
+}
+
+// Serve handles messages until the client sends the exit notification. It
+// returns an error if the connection fails or the client exits without
+// shutting down the server first.
+func (s *Server) Serve(in io.Reader, out io.Writer) error {
+	s.conn = newConn(in, out)
+	for {
+		msg, err := s.conn.read()
+		var rpcErr *responseError
+		switch {
+		case errors.As(err, &rpcErr):
+			if err := s.conn.reply(nil, nil, err); err != nil {
+				return err
+			}
+			continue
+		case err == io.EOF:
+			return errors.New("connection closed before exit")
+		case err != nil:
+			return err
+		}
+
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("exit before shutdown")
+			}
+			return nil
+		}
+		result, err := s.handle(msg)
+		if msg.ID == nil {
+			// there is no way to report errors of notifications
+			continue
+		}
+		if err := s.conn.reply(msg.ID, result, err); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(msg message) (interface{}, error) {
+	if s.shutdown && msg.ID != nil {
+		return nil, &responseError{invalidRequest, "server is shut down"}
+	}
+
+	switch msg.Method {
+	case "initialize":
+		return InitializeResult{
+			Capabilities: ServerCapabilities{
+				TextDocumentSync:           textDocumentSyncFull,
+				HoverProvider:              true,
+				CompletionProvider:         CompletionOptions{TriggerCharacters: []string{"#"}},
+				DocumentFormattingProvider: true,
+			},
+			ServerInfo: ServerInfo{Name: "usdx"},
+		}, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		s.docs[params.TextDocument.URI] = params.TextDocument.Text
+		return nil, s.publishDiagnostics(params.TextDocument.URI)
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		if n := len(params.ContentChanges); n > 0 {
+			s.docs[params.TextDocument.URI] = params.ContentChanges[n-1].Text
+		}
+		return nil, s.publishDiagnostics(params.TextDocument.URI)
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		delete(s.docs, params.TextDocument.URI)
+		return nil, s.conn.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+			URI:         params.TextDocument.URI,
+			Diagnostics: []Diagnostic{},
+		})
+	case "textDocument/hover":
+		var params TextDocumentPositionParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.hover(params)
+	case "textDocument/completion":
+		var params TextDocumentPositionParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.complete(params)
+	case "textDocument/formatting":
+		var params DocumentFormattingParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.format(params)
+	}
+
+	if msg.ID != nil {
+		return nil, &responseError{methodNotFound, fmt.Sprintf("method '%v' is not supported", msg.Method)}
+	}
+	// other notifications, like initialized or $/cancelRequest, need no
+	// handling
+	return nil, nil
+}
+
+func unmarshalParams(msg message, v interface{}) error {
+	if err := json.Unmarshal(msg.Params, v); err != nil {
+		return &responseError{invalidParams, err.Error()}
+	}
+	return nil
+}
+
+// document returns the text of an open document and reads the song from it.
+func (s *Server) document(uri string) (string, usdx.Song, []usdx.Diagnostic, error) {
+	text, ok := s.docs[uri]
+	if !ok {
+		return "", usdx.Song{}, nil, &responseError{invalidParams, fmt.Sprintf("document '%v' is not open", uri)}
+	}
+	dir, file := "", uri
+	if u, err := url.Parse(uri); err == nil && u.Scheme == "file" {
+		dir, file = filepath.Split(filepath.FromSlash(u.Path))
+	}
+	song, diagnostics, err := s.reader.Read(strings.NewReader(text), dir, file)
+	return text, song, diagnostics, err
+}
+
+func (s *Server) publishDiagnostics(uri string) error {
+	text, song, diagnostics, err := s.document(uri)
+	lines := usdx.SplitLines(text)
+	result := []Diagnostic{}
+	add := func(line int, severity usdx.Severity, code, message string) {
+		d := Diagnostic{
+			Range:    lineRange(lines, line),
+			Severity: SeverityInformation,
+			Code:     code,
+			Source:   "usdx",
+			Message:  message,
+		}
+		switch severity {
+		case usdx.SeverityError:
+			d.Severity = SeverityError
+		case usdx.SeverityWarning:
+			d.Severity = SeverityWarning
+		}
+		result = append(result, d)
+	}
+
+	for _, d := range diagnostics {
+		if !s.linter.Enabled(string(d.Code)) {
+			continue
+		}
+		add(d.Line, d.Severity, string(d.Code), d.Message)
+	}
+	if err != nil {
+		add(0, usdx.SeverityError, "", err.Error())
+	} else {
+		problems, _ := s.linter.Lint(song)
+		for _, p := range problems {
+			add(p.Line, p.Severity, p.Rule, p.Message)
+		}
+	}
+
+	return s.conn.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: result,
+	})
+}
+
+// hover describes the note or line break at the position, giving times as
+// the offset from the start of the audio file.
+func (s *Server) hover(params TextDocumentPositionParams) (*Hover, error) {
+	text, song, _, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	song = song.ToAbsolute()
+	timing := song.Timing()
+	at := func(beat int) string {
+		return timing.Time(float64(beat)).Round(time.Millisecond).String()
+	}
+
+	line := params.Position.Line + 1
+	var contents string
+	for _, track := range song.Tracks {
+		for _, l := range track.Lines {
+			for _, note := range l.Notes {
+				if note.Line != line {
+					continue
+				}
+				length := timing.Time(float64(note.Start+note.Length)) - timing.Time(float64(note.Start))
+				contents = fmt.Sprintf("%v note at beat %d, %v\n\nlength: %d beats, %v\n\npitch: %d, %v",
+					note.Kind, note.Start, at(note.Start), note.Length, length.Round(time.Millisecond), note.Pitch, usdx.PitchName(note.Pitch))
+			}
+			if l.Break != nil && l.Break.Line == line {
+				contents = fmt.Sprintf("line break at beat %d, %v", l.Break.Beat, at(l.Break.Beat))
+			}
+		}
+	}
+	if contents == "" {
+		return nil, nil
+	}
+	r := lineRange(usdx.SplitLines(text), line)
+	return &Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: contents},
+		Range:    &r,
+	}, nil
+}
+
+// complete completes the names of tags known to USDX.
+func (s *Server) complete(params TextDocumentPositionParams) ([]CompletionItem, error) {
+	text, ok := s.docs[params.TextDocument.URI]
+	if !ok {
+		return nil, &responseError{invalidParams, fmt.Sprintf("document '%v' is not open", params.TextDocument.URI)}
+	}
+	lines := usdx.SplitLines(text)
+	pos := params.Position
+	if pos.Line >= len(lines) {
+		return []CompletionItem{}, nil
+	}
+	line := lines[pos.Line]
+	prefix := line[:byteOffset(line, pos.Character)]
+	if !strings.HasPrefix(prefix, "#") || strings.Contains(prefix, ":") {
+		return []CompletionItem{}, nil
+	}
+
+	// replace the whole tag, along with its colon if there is one
+	rest := line[len(prefix):]
+	n := strings.IndexFunc(rest, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if n < 0 {
+		n = len(rest)
+	} else if rest[n] == ':' {
+		n++
+	}
+	end := Position{Line: pos.Line, Character: utf16Len(prefix + rest[:n])}
+	typed := strings.ToUpper(prefix[1:])
+	items := []CompletionItem{}
+	for _, tag := range usdx.Tags() {
+		if !strings.HasPrefix(tag, typed) {
+			continue
+		}
+		items = append(items, CompletionItem{
+			Label: tag,
+			Kind:  completionKindKeyword,
+			TextEdit: &TextEdit{
+				Range:   Range{Start: Position{Line: pos.Line, Character: 1}, End: end},
+				NewText: tag + ":",
+			},
+		})
+	}
+	return items, nil
+}
+
+// format replaces the document with the song written in the canonical form.
+// Formatting is refused if the formatted text is read as a different song.
+func (s *Server) format(params DocumentFormattingParams) ([]TextEdit, error) {
+	text, song, _, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	formatted, err := s.reader.Format(song, usdx.Writer{})
+	if err != nil {
+		return nil, err
+	}
+	if string(formatted) == text {
+		return []TextEdit{}, nil
+	}
+
+	lines := usdx.SplitLines(text)
+	last := len(lines) - 1
+	return []TextEdit{{
+		Range: Range{
+			End: Position{Line: last, Character: utf16Len(lines[last])},
+		},
+		NewText: string(formatted),
+	}}, nil
+}
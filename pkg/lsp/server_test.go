@@ -0,0 +1,238 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Patagonicus/usdx-reader/pkg/lint"
+)
+
+// received is a message sent by the server, a response or a notification.
+type received struct {
+	ID     *json.RawMessage `json:"id"`
+	Method string           `json:"method"`
+	Params json.RawMessage  `json:"params"`
+	Result json.RawMessage  `json:"result"`
+	Error  *responseError   `json:"error"`
+	// raw is the whole body
+	raw string
+}
+
+// frame adds a Content-Length header to a message.
+func frame(body string) string {
+	return fmt.Sprintf("Content-Length: %d\r\n\r\n%v", len(body), body)
+}
+
+// request returns a framed request, or a notification if id is 0.
+func request(id int, method string, params interface{}) string {
+	msg := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  method,
+		"params":  params,
+	}
+	if id != 0 {
+		msg["id"] = id
+	}
+	b, err := json.Marshal(msg)
+	if err != nil {
+		panic(err)
+	}
+	return frame(string(b))
+}
+
+// serve runs a server on the given input and returns the messages it sent.
+func serve(t *testing.T, in ...string) ([]received, error) {
+	t.Helper()
+	var out bytes.Buffer
+	err := New(lint.New()).Serve(strings.NewReader(strings.Join(in, "")), &out)
+
+	var messages []received
+	r := textproto.NewReader(bufio.NewReader(&out))
+	for {
+		header, herr := r.ReadMIMEHeader()
+		if herr == io.EOF {
+			break
+		}
+		if herr != nil {
+			t.Fatalf("error reading header: %v", herr)
+		}
+		length, herr := strconv.Atoi(header.Get("Content-Length"))
+		if herr != nil {
+			t.Fatalf("invalid Content-Length: %v", herr)
+		}
+		body := make([]byte, length)
+		if _, herr := io.ReadFull(r.R, body); herr != nil {
+			t.Fatalf("error reading body: %v", herr)
+		}
+		msg := received{raw: string(body)}
+		if herr := json.Unmarshal(body, &msg); herr != nil {
+			t.Fatalf("error parsing %q: %v", body, herr)
+		}
+		messages = append(messages, msg)
+	}
+	return messages, err
+}
+
+var exit = []string{
+	request(99, "shutdown", nil),
+	request(0, "exit", nil),
+}
+
+func TestServe(t *testing.T) {
+	const uri = "file:///songs/a/song.txt"
+	text := "#TITLE:a \n#ARTIST:b\n#BPM:100\n#GAP:1000\n:  0 2 0 a\n- 4\n: 4 2 0 b\nE\n"
+	messages, err := serve(t, append([]string{
+		request(1, "initialize", map[string]interface{}{}),
+		request(0, "initialized", map[string]interface{}{}),
+		request(0, "textDocument/didOpen", DidOpenTextDocumentParams{
+			TextDocument: TextDocumentItem{URI: uri, LanguageID: "usdx", Version: 1, Text: text},
+		}),
+		request(2, "textDocument/hover", TextDocumentPositionParams{
+			TextDocument: TextDocumentIdentifier{URI: uri},
+			Position:     Position{Line: 6, Character: 2},
+		}),
+		request(3, "textDocument/hover", TextDocumentPositionParams{
+			TextDocument: TextDocumentIdentifier{URI: uri},
+			Position:     Position{Line: 0, Character: 2},
+		}),
+		request(4, "textDocument/completion", TextDocumentPositionParams{
+			TextDocument: TextDocumentIdentifier{URI: uri},
+			Position:     Position{Line: 1, Character: 3},
+		}),
+		request(5, "textDocument/formatting", DocumentFormattingParams{
+			TextDocument: TextDocumentIdentifier{URI: uri},
+		}),
+		request(6, "unknown/method", nil),
+	}, exit...)...)
+	if err != nil {
+		t.Fatalf("error serving: %v", err)
+	}
+	if len(messages) != 8 {
+		t.Fatalf("expected 8 messages, got %d: %v", len(messages), messages)
+	}
+
+	var initialize InitializeResult
+	decode(t, messages[0], 1, &initialize)
+	if !initialize.Capabilities.HoverProvider || !initialize.Capabilities.DocumentFormattingProvider || initialize.Capabilities.TextDocumentSync != textDocumentSyncFull {
+		t.Errorf("unexpected capabilities %+v", initialize.Capabilities)
+	}
+
+	var diagnostics PublishDiagnosticsParams
+	if messages[1].Method != "textDocument/publishDiagnostics" {
+		t.Fatalf("expected diagnostics, got %v", messages[1].raw)
+	}
+	if err := json.Unmarshal(messages[1].Params, &diagnostics); err != nil {
+		t.Fatalf("error parsing diagnostics: %v", err)
+	}
+	if diagnostics.URI != uri || len(diagnostics.Diagnostics) != 1 {
+		t.Fatalf("expected one diagnostic for %v, got %+v", uri, diagnostics)
+	}
+	want := Diagnostic{
+		Range:    Range{Start: Position{Line: 0}, End: Position{Line: 0, Character: 9}},
+		Severity: SeverityInformation,
+		Code:     "tag-whitespace",
+		Source:   "usdx",
+		Message:  "value of tag 'TITLE' ends with whitespace",
+	}
+	if got := diagnostics.Diagnostics[0]; got != want {
+		t.Errorf("expected diagnostic %+v, got %+v", want, got)
+	}
+
+	var hover Hover
+	decode(t, messages[2], 2, &hover)
+	if !strings.HasPrefix(hover.Contents.Value, "normal note at beat 4, 1.6s") || hover.Range == nil || hover.Range.Start.Line != 6 {
+		t.Errorf("unexpected hover %+v", hover)
+	}
+	if string(messages[3].Result) != "null" || messages[3].Error != nil {
+		t.Errorf("expected no hover on a tag, got %v", messages[3].raw)
+	}
+
+	var items []CompletionItem
+	decode(t, messages[4], 4, &items)
+	labels := make(map[string]bool)
+	for _, item := range items {
+		labels[item.Label] = true
+		if !strings.HasPrefix(item.Label, "AR") {
+			t.Errorf("unexpected completion %v", item.Label)
+		}
+	}
+	if !labels["ARTIST"] {
+		t.Errorf("expected ARTIST to be completed, got %+v", items)
+	}
+	if edit := items[0].TextEdit; edit == nil || edit.Range != (Range{Start: Position{Line: 1, Character: 1}, End: Position{Line: 1, Character: 8}}) {
+		t.Errorf("expected the completion to replace the tag and its colon, got %+v", edit)
+	}
+
+	var edits []TextEdit
+	decode(t, messages[5], 5, &edits)
+	formatted := "#TITLE:a \n#ARTIST:b\n#BPM:100\n#GAP:1000\n: 0 2 0 a\n- 4\n: 4 2 0 b\nE\n"
+	wantEdits := []TextEdit{{
+		Range:   Range{End: Position{Line: 8}},
+		NewText: formatted,
+	}}
+	if fmt.Sprint(edits) != fmt.Sprint(wantEdits) {
+		t.Errorf("expected edits %+v, got %+v", wantEdits, edits)
+	}
+
+	if messages[6].Error == nil || messages[6].Error.Code != methodNotFound {
+		t.Errorf("expected method not found, got %v", messages[6].raw)
+	}
+	if string(messages[7].Result) != "null" || messages[7].Error != nil {
+		t.Errorf("expected a null result for shutdown, got %v", messages[7].raw)
+	}
+}
+
+func TestServeInvalidMessages(t *testing.T) {
+	messages, err := serve(t, append([]string{
+		frame("{not json"),
+		"Content-Length: x\r\n\r\n" + `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`,
+		request(2, "initialize", map[string]interface{}{}),
+	}, exit...)...)
+	if err != nil {
+		t.Fatalf("error serving: %v", err)
+	}
+	if len(messages) != 4 {
+		t.Fatalf("expected 4 messages, got %d: %v", len(messages), messages)
+	}
+	for _, msg := range messages[:2] {
+		if msg.Error == nil || msg.Error.Code != parseError {
+			t.Errorf("expected a parse error, got %v", msg.raw)
+		}
+		if !strings.Contains(msg.raw, `"id":null`) {
+			t.Errorf("expected a null ID, got %v", msg.raw)
+		}
+	}
+	var initialize InitializeResult
+	decode(t, messages[2], 2, &initialize)
+}
+
+func TestServeExit(t *testing.T) {
+	if _, err := serve(t, request(0, "exit", nil)); err == nil {
+		t.Errorf("expected an error for exit before shutdown")
+	}
+	if _, err := serve(t, request(1, "initialize", map[string]interface{}{})); err == nil {
+		t.Errorf("expected an error for a closed connection")
+	}
+}
+
+// decode checks that msg is a successful response to the request with the
+// given ID and parses its result.
+func decode(t *testing.T, msg received, id int, result interface{}) {
+	t.Helper()
+	if msg.ID == nil || string(*msg.ID) != strconv.Itoa(id) {
+		t.Fatalf("expected a response to request %d, got %v", id, msg.raw)
+	}
+	if msg.Error != nil {
+		t.Fatalf("unexpected error in response to request %d: %v", id, msg.Error)
+	}
+	if err := json.Unmarshal(msg.Result, result); err != nil {
+		t.Fatalf("error parsing result of request %d: %v", id, err)
+	}
+}
@@ -0,0 +1,65 @@
+package lsp
+
+// textEncoding is used for #ENCODING tags in documents, which the editor has
+// already decoded. It keeps the name of the encoding, so that formatting keeps
+// the tag, but does not change the text.
+type textEncoding string
+
+func (e textEncoding) Name() string {
+	return string(e)
+}
+
+func (e textEncoding) Decode(s string) (string, error) {
+	return s, nil
+}
+
+func (e textEncoding) Encode(s string) (string, error) {
+	return s, nil
+}
+
+// utf16Len returns the length of s in UTF-16 code units, which the protocol
+// uses for positions in a line.
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += runeLen(r)
+	}
+	return n
+}
+
+// runeLen returns the number of UTF-16 code units needed for r.
+func runeLen(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+// byteOffset returns the offset in bytes of a position given in UTF-16 code
+// units. Positions after the end of the line are moved to its end.
+func byteOffset(s string, character int) int {
+	n := 0
+	for i, r := range s {
+		if n >= character {
+			return i
+		}
+		n += runeLen(r)
+	}
+	return len(s)
+}
+
+// lineRange returns the range of a whole line, or of the first line if line
+// is 0. Lines start at 1, like in diagnostics of the reader.
+func lineRange(lines []string, line int) Range {
+	i := line - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(lines) {
+		i = len(lines) - 1
+	}
+	return Range{
+		Start: Position{Line: i},
+		End:   Position{Line: i, Character: utf16Len(lines[i])},
+	}
+}
@@ -3,7 +3,9 @@ package mysql
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 	"log"
+	"strconv"
 	"strings"
 
 	"github.com/Patagonicus/usdx-reader/pkg/storage"
@@ -12,10 +14,23 @@ import (
 )
 
 type MySQL struct {
-	db         *sql.DB
-	insertSong *sql.Stmt
-	insertTag  *sql.Stmt
-	selectAll  *sql.Stmt
+	db           *sql.DB
+	insertSong   *sql.Stmt
+	insertTag    *sql.Stmt
+	insertValues map[string]*sql.Stmt
+	selectAll    *sql.Stmt
+	selectValues *sql.Stmt
+}
+
+// lists maps the tags with multiple values to the tables they are stored in.
+var lists = []struct {
+	tag   string
+	table string
+}{
+	{"GENRE", "genres"},
+	{"LANGUAGE", "languages"},
+	{"EDITION", "editions"},
+	{"TAGS", "tags"},
 }
 
 func OpenExisting(dataSourceName string) (*MySQL, error) {
@@ -24,31 +39,43 @@ func OpenExisting(dataSourceName string) (*MySQL, error) {
 		return nil, err
 	}
 
-	insertSong, insertTag, selectAll, err := prepareStatements(db)
-
+	m, err := prepareStatements(db)
 	if err != nil {
 		db.Close()
 		return nil, err
 	}
-	return &MySQL{
-		db:         db,
-		insertSong: insertSong,
-		insertTag:  insertTag,
-		selectAll:  selectAll,
-	}, nil
+	return m, nil
 }
 
-func prepareStatements(db *sql.DB) (insertSong *sql.Stmt, insertTag *sql.Stmt, selectAll *sql.Stmt, err error) {
+func prepareStatements(db *sql.DB) (m *MySQL, err error) {
+	m = &MySQL{
+		db:           db,
+		insertValues: make(map[string]*sql.Stmt),
+	}
+
 	if err == nil {
-		insertSong, err = db.Prepare("INSERT INTO songs (directory, source, title, artist, sound, bpm, gap, cover, background, video, video_gap, genre, edition, creator, language, year, start, end, resolution, notes_gap, relative, preview_start, medley_start_beat, medley_end_beat, calc_medley, player1, player2, notes) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+		m.insertSong, err = db.Prepare("INSERT INTO songs (directory, source, title, artist, sound, bpm, gap, cover, background, video, video_gap, genre, edition, creator, language, year, start, end, resolution, notes_gap, relative, preview_start, medley_start_beat, medley_end_beat, calc_medley, player1, player2, version, audio, vocals, instrumental, tags, provided_by, audio_url, cover_url, video_url, notes) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
 	}
 
 	if err == nil {
-		insertTag, err = db.Prepare("INSERT INTO custom (song, tag, content) VALUES (?, ?, ?)")
+		m.insertTag, err = db.Prepare("INSERT INTO custom (song, tag, content) VALUES (?, ?, ?)")
+	}
+
+	var selects []string
+	for _, list := range lists {
+		if err == nil {
+			m.insertValues[list.tag], err = db.Prepare("INSERT INTO " + list.table + " (song, position, value) VALUES (?, ?, ?)")
+		}
+		selects = append(selects, fmt.Sprintf("SELECT song, '%v', position, value FROM %v", list.tag, list.table))
 	}
 
 	if err == nil {
-		selectAll, err = db.Prepare("SELECT directory, source, title, artist, sound, bpm, gap, cover, background, video, video_gap, genre, edition, creator, language, year, start, end, resolution, notes_gap, relative, preview_start, medley_start_beat, medley_end_beat, calc_medley, player1, player2, notes FROM songs ORDER BY ID ASC")
+		m.selectAll, err = db.Prepare("SELECT ID, directory, source, title, artist, sound, bpm, gap, cover, background, video, video_gap, genre, edition, creator, language, year, start, end, resolution, notes_gap, relative, preview_start, medley_start_beat, medley_end_beat, calc_medley, player1, player2, version, audio, vocals, instrumental, tags, provided_by, audio_url, cover_url, video_url, notes FROM songs ORDER BY ID ASC")
+	}
+
+	if err == nil {
+		// ordered by song like selectAll, see listValues
+		m.selectValues, err = db.Prepare(strings.Join(selects, " UNION ALL ") + " ORDER BY song ASC, position ASC")
 	}
 
 	return
@@ -60,8 +87,11 @@ func OpenNew(dataSourceName string) (*MySQL, error) {
 		return nil, err
 	}
 
-	err = execute(db,
-		"DROP TABLE IF EXISTS custom",
+	statements := []string{"DROP TABLE IF EXISTS custom"}
+	for _, list := range lists {
+		statements = append(statements, "DROP TABLE IF EXISTS "+list.table)
+	}
+	statements = append(statements,
 		"DROP TABLE IF EXISTS songs",
 		`CREATE TABLE songs (
 			ID int NOT NULL AUTO_INCREMENT PRIMARY KEY,
@@ -92,6 +122,15 @@ func OpenNew(dataSourceName string) (*MySQL, error) {
 			calc_medley BOOL,
 			player1 VARCHAR(512),
 			player2 VARCHAR(512),
+			version VARCHAR(32),
+			audio VARCHAR(512),
+			vocals VARCHAR(512),
+			instrumental VARCHAR(512),
+			tags VARCHAR(512),
+			provided_by VARCHAR(512),
+			audio_url VARCHAR(1024),
+			cover_url VARCHAR(1024),
+			video_url VARCHAR(1024),
 			notes LONGTEXT
 		)`,
 		`CREATE TABLE custom (
@@ -102,23 +141,29 @@ func OpenNew(dataSourceName string) (*MySQL, error) {
 			FOREIGN KEY (song) REFERENCES songs(ID) ON DELETE CASCADE
 		)`,
 	)
+	// one table per list, so that songs can be found by each value
+	for _, list := range lists {
+		statements = append(statements, `CREATE TABLE `+list.table+` (
+			ID int NOT NULL AUTO_INCREMENT PRIMARY KEY,
+			song INT,
+			position INT,
+			value VARCHAR(512),
+			INDEX (value),
+			FOREIGN KEY (song) REFERENCES songs(ID) ON DELETE CASCADE
+		)`)
+	}
+	err = execute(db, statements...)
 	if err != nil {
 		db.Close()
 		return nil, err
 	}
 
-	insertSong, insertTag, selectAll, err := prepareStatements(db)
-
+	m, err := prepareStatements(db)
 	if err != nil {
 		db.Close()
 		return nil, err
 	}
-	return &MySQL{
-		db:         db,
-		insertSong: insertSong,
-		insertTag:  insertTag,
-		selectAll:  selectAll,
-	}, nil
+	return m, nil
 }
 
 func (m *MySQL) Close() error {
@@ -131,35 +176,51 @@ func (m *MySQL) InsertSong(song usdx.Song) error {
 		return err
 	}
 
+	// tags missing from the file are stored as NULL
+	present := func(tag string, value interface{}) interface{} {
+		if len(song.Header) > 0 && !song.HasTag(tag) {
+			return nil
+		}
+		return value
+	}
 	result, err := tx.Stmt(m.insertSong).Exec(
 		song.Dir,
 		song.SourceFile,
-		song.Title,
-		song.Artist,
-		song.SoundFile,
-		song.BPM,
-		song.Gap,
-		song.CoverPath,
-		song.BackgroundPath,
-		song.VideoPath,
-		song.VideoGap,
-		song.Genre,
-		song.Edition,
-		song.Creator,
-		song.Language,
-		song.Year,
-		song.Start,
-		song.End,
-		song.Resolution,
-		song.NotesGap,
-		song.Relative,
-		song.PreviewStart,
-		song.MedleyStartBeat,
-		song.MedleyEndBeat,
-		song.CalcMedley,
-		song.DuetSingerP1,
-		song.DuetSingerP2,
-		strings.Join(song.Notes, "\r\n"),
+		present("TITLE", song.Title),
+		present("ARTIST", song.Artist),
+		present("MP3", song.SoundFile),
+		present("BPM", song.BPM),
+		present("GAP", song.Gap),
+		present("COVER", song.CoverPath),
+		present("BACKGROUND", song.BackgroundPath),
+		present("VIDEO", song.VideoPath),
+		present("VIDEOGAP", song.VideoGap),
+		present("GENRE", song.Genre),
+		present("EDITION", song.Edition),
+		present("CREATOR", song.Creator),
+		present("LANGUAGE", song.Language),
+		present("YEAR", song.Year),
+		present("START", song.Start),
+		present("END", song.End),
+		present("RESOLUTION", song.Resolution),
+		present("NOTESGAP", song.NotesGap),
+		present("RELATIVE", song.Relative),
+		present("PREVIEWSTART", song.PreviewStart),
+		present("MEDLEYSTARTBEAT", song.MedleyStartBeat),
+		present("MEDLEYENDBEAT", song.MedleyEndBeat),
+		present("CALCMEDLEY", song.CalcMedley),
+		present("P1", song.DuetSingerP1),
+		present("P2", song.DuetSingerP2),
+		present("VERSION", formatVersion(song.Version)),
+		present("AUDIO", song.Audio),
+		present("VOCALS", song.Vocals),
+		present("INSTRUMENTAL", song.Instrumental),
+		present("TAGS", song.Tags),
+		present("PROVIDEDBY", song.ProvidedBy),
+		present("AUDIOURL", song.AudioURL),
+		present("COVERURL", song.CoverURL),
+		present("VIDEOURL", song.VideoURL),
+		strings.Join(song.NoteLines(), "\r\n"),
 	)
 	if err != nil {
 		rollbackErr := tx.Rollback()
@@ -183,21 +244,46 @@ func (m *MySQL) InsertSong(song usdx.Song) error {
 		}
 	}
 
+	values := map[string][]string{
+		"GENRE":    song.Genres,
+		"LANGUAGE": song.Languages,
+		"EDITION":  song.Editions,
+		"TAGS":     song.TagList,
+	}
+	for _, list := range lists {
+		insertValue := tx.Stmt(m.insertValues[list.tag])
+		for i, value := range values[list.tag] {
+			_, err = insertValue.Exec(songID, i, value)
+			if err != nil {
+				rollbackErr := tx.Rollback()
+				log.Printf("error rolling back transaction: %v", rollbackErr)
+				return err
+			}
+		}
+	}
+
 	return tx.Commit()
 }
 
 func (m *MySQL) GetAll() storage.Result {
 	rows, err := m.selectAll.Query()
-	return &result{
+	r := &result{
 		rows: rows,
 		err:  err,
 	}
+	if err == nil {
+		r.valueRows, r.err = m.selectValues.Query()
+		r.values = &listValues{rows: r.valueRows}
+	}
+	return r
 }
 
 type result struct {
-	rows *sql.Rows
-	song usdx.Song
-	err  error
+	rows      *sql.Rows
+	valueRows *sql.Rows
+	values    *listValues
+	song      usdx.Song
+	err       error
 }
 
 func (r *result) Next() bool {
@@ -212,35 +298,56 @@ func (r *result) Next() bool {
 		return false
 	}
 
-	var notes string
+	r.song = usdx.Song{
+		Resolution: 4,
+		CalcMedley: true,
+	}
+	var columns []*column
+	tag := func(tag string, dest interface{}) *column {
+		c := &column{tag: tag, dest: dest}
+		columns = append(columns, c)
+		return c
+	}
+	var id int64
+	var version, notes string
 	err := r.rows.Scan(
+		&id,
 		&r.song.Dir,
 		&r.song.SourceFile,
-		&r.song.Title,
-		&r.song.Artist,
-		&r.song.SoundFile,
-		&r.song.BPM,
-		&r.song.Gap,
-		&r.song.CoverPath,
-		&r.song.BackgroundPath,
-		&r.song.VideoPath,
-		&r.song.VideoGap,
-		&r.song.Genre,
-		&r.song.Edition,
-		&r.song.Creator,
-		&r.song.Language,
-		&r.song.Year,
-		&r.song.Start,
-		&r.song.End,
-		&r.song.Resolution,
-		&r.song.NotesGap,
-		&r.song.Relative,
-		&r.song.PreviewStart,
-		&r.song.MedleyStartBeat,
-		&r.song.MedleyEndBeat,
-		&r.song.CalcMedley,
-		&r.song.DuetSingerP1,
-		&r.song.DuetSingerP2,
+		tag("TITLE", &r.song.Title),
+		tag("ARTIST", &r.song.Artist),
+		tag("MP3", &r.song.SoundFile),
+		tag("BPM", &r.song.BPM),
+		tag("GAP", &r.song.Gap),
+		tag("COVER", &r.song.CoverPath),
+		tag("BACKGROUND", &r.song.BackgroundPath),
+		tag("VIDEO", &r.song.VideoPath),
+		tag("VIDEOGAP", &r.song.VideoGap),
+		tag("GENRE", &r.song.Genre),
+		tag("EDITION", &r.song.Edition),
+		tag("CREATOR", &r.song.Creator),
+		tag("LANGUAGE", &r.song.Language),
+		tag("YEAR", &r.song.Year),
+		tag("START", &r.song.Start),
+		tag("END", &r.song.End),
+		tag("RESOLUTION", &r.song.Resolution),
+		tag("NOTESGAP", &r.song.NotesGap),
+		tag("RELATIVE", &r.song.Relative),
+		tag("PREVIEWSTART", &r.song.PreviewStart),
+		tag("MEDLEYSTARTBEAT", &r.song.MedleyStartBeat),
+		tag("MEDLEYENDBEAT", &r.song.MedleyEndBeat),
+		tag("CALCMEDLEY", &r.song.CalcMedley),
+		tag("P1", &r.song.DuetSingerP1),
+		tag("P2", &r.song.DuetSingerP2),
+		tag("VERSION", &version),
+		tag("AUDIO", &r.song.Audio),
+		tag("VOCALS", &r.song.Vocals),
+		tag("INSTRUMENTAL", &r.song.Instrumental),
+		tag("TAGS", &r.song.Tags),
+		tag("PROVIDEDBY", &r.song.ProvidedBy),
+		tag("AUDIOURL", &r.song.AudioURL),
+		tag("COVERURL", &r.song.CoverURL),
+		tag("VIDEOURL", &r.song.VideoURL),
 		&notes,
 	)
 	if err != nil {
@@ -248,7 +355,36 @@ func (r *result) Next() bool {
 		return false
 	}
 
-	r.song.Notes = strings.Split(notes, "\r\n")
+	// the header is rebuilt in column order, as the original order is not
+	// stored
+	for _, c := range columns {
+		if c.present {
+			r.song.Header = append(r.song.Header, usdx.HeaderTag{
+				Tag:   c.tag,
+				Value: c.value,
+				Raw:   "#" + c.tag + ":" + c.value,
+			})
+		}
+	}
+
+	r.song.Version = usdx.Legacy
+	if version != "" {
+		r.song.Version, err = usdx.ParseVersion(version)
+		if err != nil {
+			r.err = err
+			return false
+		}
+	}
+
+	err = r.values.read(id, &r.song)
+	if err != nil {
+		r.err = err
+		return false
+	}
+
+	for _, warning := range usdx.ParseNotes(&r.song, strings.Split(notes, "\r\n")) {
+		log.Printf("error parsing notes of %v: %v", r.song.SourceFile, warning)
+	}
 	return true
 }
 
@@ -261,9 +397,116 @@ func (r *result) Err() error {
 }
 
 func (r *result) Close() error {
+	if r.valueRows != nil {
+		r.valueRows.Close()
+	}
 	return r.rows.Close()
 }
 
+type rowScanner interface {
+	Next() bool
+	Scan(dest ...interface{}) error
+	Err() error
+}
+
+// listValues reads the values of the lists of all songs from a single query.
+// The rows are ordered by song ID like the songs, so the values of a song are
+// the rows up to the first row of a later song.
+type listValues struct {
+	rows rowScanner
+	// pending is set if the row in song, tag and value has not been used yet
+	pending bool
+	song    int64
+	tag     string
+	value   string
+}
+
+// read adds the values of the song with the given ID to song. Values of songs
+// with smaller IDs are skipped.
+func (v *listValues) read(id int64, song *usdx.Song) error {
+	for {
+		if !v.pending {
+			if !v.rows.Next() {
+				return v.rows.Err()
+			}
+			var position int
+			if err := v.rows.Scan(&v.song, &v.tag, &position, &v.value); err != nil {
+				return err
+			}
+			v.pending = true
+		}
+		if v.song > id {
+			return nil
+		}
+		v.pending = false
+		if v.song < id {
+			continue
+		}
+		switch v.tag {
+		case "GENRE":
+			song.Genres = append(song.Genres, v.value)
+		case "LANGUAGE":
+			song.Languages = append(song.Languages, v.value)
+		case "EDITION":
+			song.Editions = append(song.Editions, v.value)
+		case "TAGS":
+			song.TagList = append(song.TagList, v.value)
+		}
+	}
+}
+
+// column scans a column that is NULL if the song did not have the tag.
+type column struct {
+	tag     string
+	dest    interface{}
+	present bool
+	value   string
+}
+
+func (c *column) Scan(src interface{}) error {
+	var value sql.NullString
+	if err := value.Scan(src); err != nil {
+		return err
+	}
+	c.present, c.value = value.Valid, value.String
+	if !value.Valid {
+		return nil
+	}
+
+	switch dest := c.dest.(type) {
+	case *string:
+		*dest = value.String
+	case *int:
+		i, err := strconv.Atoi(value.String)
+		if err != nil {
+			return err
+		}
+		*dest = i
+	case *float32:
+		f, err := strconv.ParseFloat(value.String, 32)
+		if err != nil {
+			return err
+		}
+		*dest = float32(f)
+	case *bool:
+		b, err := strconv.ParseBool(value.String)
+		if err != nil {
+			return err
+		}
+		*dest = b
+	default:
+		return fmt.Errorf("unsupported destination %T for tag '%v'", c.dest, c.tag)
+	}
+	return nil
+}
+
+func formatVersion(v usdx.Version) string {
+	if v.IsLegacy() {
+		return ""
+	}
+	return v.String()
+}
+
 func execute(db *sql.DB, statements ...string) error {
 	for _, statement := range statements {
 		_, err := db.Exec(statement)
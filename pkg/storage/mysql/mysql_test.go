@@ -0,0 +1,116 @@
+package mysql
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Patagonicus/usdx-reader/pkg/usdx"
+)
+
+// fakeRows returns rows of song, tag, position and value.
+type fakeRows struct {
+	rows [][]interface{}
+	next int
+}
+
+func (f *fakeRows) Next() bool {
+	f.next++
+	return f.next <= len(f.rows)
+}
+
+func (f *fakeRows) Scan(dest ...interface{}) error {
+	row := f.rows[f.next-1]
+	*dest[0].(*int64) = int64(row[0].(int))
+	*dest[1].(*string) = row[1].(string)
+	*dest[2].(*int) = row[2].(int)
+	*dest[3].(*string) = row[3].(string)
+	return nil
+}
+
+func (f *fakeRows) Err() error {
+	return nil
+}
+
+func TestListValues(t *testing.T) {
+	v := &listValues{rows: &fakeRows{rows: [][]interface{}{
+		{1, "GENRE", 0, "Pop"},
+		{1, "LANGUAGE", 0, "English"},
+		{1, "GENRE", 1, "Rock"},
+		{2, "TAGS", 0, "skipped"},
+		{4, "EDITION", 0, "SingStar"},
+		{4, "TAGS", 0, "a"},
+		{4, "TAGS", 1, "b"},
+		{5, "GENRE", 0, "Jazz"},
+	}}}
+
+	want := []usdx.Song{
+		{Genres: []string{"Pop", "Rock"}, Languages: []string{"English"}},
+		{},
+		{Editions: []string{"SingStar"}, TagList: []string{"a", "b"}},
+		{Genres: []string{"Jazz"}},
+		{},
+	}
+	for i, id := range []int64{1, 3, 4, 5, 6} {
+		var song usdx.Song
+		if err := v.read(id, &song); err != nil {
+			t.Fatalf("error reading values of song %d: %v", id, err)
+		}
+		if !reflect.DeepEqual(song, want[i]) {
+			t.Errorf("song %d: expected %+v, got %+v", id, want[i], song)
+		}
+	}
+}
+
+// TestInsertAndGetAll needs an empty MySQL database given by the data source
+// name in USDX_MYSQL_TEST. Its tables are dropped.
+func TestInsertAndGetAll(t *testing.T) {
+	dsn := os.Getenv("USDX_MYSQL_TEST")
+	if dsn == "" {
+		t.Skip("USDX_MYSQL_TEST is not set")
+	}
+	m, err := OpenNew(dsn)
+	if err != nil {
+		t.Fatalf("error opening database: %v", err)
+	}
+	defer m.Close()
+
+	var songs []usdx.Song
+	for _, text := range []string{
+		"#TITLE:a\r\n#ARTIST:b\r\n#BPM:100\r\n#GENRE:Pop, Rock\r\n#LANGUAGE:English\r\n: 0 2 0 a\r\nE\r\n",
+		"#TITLE:c\r\n#ARTIST:d\r\n#BPM:100\r\n: 0 2 0 a\r\nE\r\n",
+		"#VERSION:1.1.0\r\n#TITLE:e\r\n#ARTIST:f\r\n#BPM:100\r\n#EDITION:SingStar\r\n#TAGS:x, y, z\r\n: 0 2 0 a\r\nE\r\n",
+	} {
+		song, _, err := usdx.NewReader().Read(strings.NewReader(text), "", "song.txt")
+		if err != nil {
+			t.Fatalf("error reading song: %v", err)
+		}
+		if err := m.InsertSong(song); err != nil {
+			t.Fatalf("error inserting song: %v", err)
+		}
+		songs = append(songs, song)
+	}
+
+	result := m.GetAll()
+	defer result.Close()
+	for i := 0; result.Next(); i++ {
+		if i >= len(songs) {
+			t.Fatalf("expected %d songs, got more", len(songs))
+		}
+		got, want := result.Song(), songs[i]
+		for _, list := range [][2][]string{
+			{got.Genres, want.Genres},
+			{got.Languages, want.Languages},
+			{got.Editions, want.Editions},
+			{got.TagList, want.TagList},
+		} {
+			if !reflect.DeepEqual(list[0], list[1]) {
+				t.Errorf("song %d: expected values %q, got %q", i, list[1], list[0])
+			}
+		}
+	}
+	if err := result.Err(); err != nil {
+		t.Fatalf("error reading songs: %v", err)
+	}
+}
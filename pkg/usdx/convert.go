@@ -0,0 +1,67 @@
+package usdx
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/Patagonicus/usdx-reader/pkg/encoding"
+)
+
+// Convert returns a copy of the song that can be written in the given format
+// version.
+//
+// Converting to version 1.0.0 or newer makes the song absolute, keeping its
+// medley beats, switches it to UTF-8 and rounds #GAP to whole milliseconds.
+// From 1.1.0 on, the audio file is stored in #AUDIO instead of #MP3. When
+// converting to an older version, the audio file is moved back to #MP3 and
+// fields for tags unknown to the target version are kept as custom tags, which
+// older versions of USDX ignore. Songs converted to legacy files get an
+// #ENCODING tag for UTF-8.
+func (s Song) Convert(to Version) (Song, error) {
+	if to.Compare(LatestVersion) > 0 {
+		return s, fmt.Errorf("unsupported format version %v", to)
+	}
+
+	s.Version = to
+	s.CustomTags = append([]Tag(nil), s.CustomTags...)
+	s.Encoding = encoding.UTF8
+
+	if to.AtLeast(Version100) {
+		s = s.ToAbsolute()
+		s.BOM = false
+		s.Resolution = 4
+		s.NotesGap = 0
+		s.Gap = float32(math.Round(float64(s.Gap)))
+	}
+
+	if to.AtLeast(Version110) {
+		s.Audio = s.AudioFile()
+		s.SoundFile = ""
+	} else {
+		s.SoundFile = s.AudioFile()
+		s.Audio = ""
+		s.moveToCustom("VOCALS", &s.Vocals)
+		s.moveToCustom("INSTRUMENTAL", &s.Instrumental)
+		s.moveToCustom("TAGS", &s.Tags)
+		s.moveToCustom("PROVIDEDBY", &s.ProvidedBy)
+	}
+
+	if !to.AtLeast(Version120) {
+		s.moveToCustom("AUDIOURL", &s.AudioURL)
+		s.moveToCustom("COVERURL", &s.CoverURL)
+		s.moveToCustom("VIDEOURL", &s.VideoURL)
+	}
+
+	return s, nil
+}
+
+func (s *Song) moveToCustom(tag string, value *string) {
+	if *value == "" {
+		return
+	}
+	s.CustomTags = append(s.CustomTags, Tag{
+		Tag:     tag,
+		Content: *value,
+	})
+	*value = ""
+}
@@ -0,0 +1,77 @@
+package usdx
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		text   string
+		syntax bool
+		to     Version
+		want   string
+	}{
+		{
+			name: "relative with medley",
+			text: "#TITLE:a\n#ARTIST:b\n#MP3:a.mp3\n#BPM:100\n#RELATIVE:yes\n#MEDLEYSTARTBEAT:6\n#MEDLEYENDBEAT:13\n: 0 2 0 a\n- 4 6\n: 0 2 0 c\n- 2 4\n: 1 2 0 d\nE\n",
+			to:   Version100,
+			want: "#VERSION:1.0.0\n#TITLE:a\n#ARTIST:b\n#MP3:a.mp3\n#BPM:100\n#MEDLEYSTARTBEAT:6\n#MEDLEYENDBEAT:13\n: 0 2 0 a\n- 4\n: 6 2 0 c\n- 8\n: 11 2 0 d\nE\n",
+		},
+		{
+			name: "audio and duet singers",
+			text: "#TITLE:a\n#ARTIST:b\n#MP3:a.mp3\n#BPM:100\n#GAP:12.7\n#DUETSINGERP1:x\n#DUETSINGERP2:y\nP1\n: 0 2 0 a\nP2\n: 0 2 0 b\nE\n",
+			to:   Version110,
+			want: "#VERSION:1.1.0\n#TITLE:a\n#ARTIST:b\n#AUDIO:a.mp3\n#BPM:100\n#GAP:13\n#P1:x\n#P2:y\nP1\n: 0 2 0 a\nP2\n: 0 2 0 b\nE\n",
+		},
+		{
+			name: "downgrade",
+			text: "#VERSION:1.1.0\n#TITLE:a\n#ARTIST:b\n#AUDIO:a.mp3\n#BPM:100\n#PROVIDEDBY:me\n: 0 2 0 a\nE\n",
+			to:   Legacy,
+			want: "#ENCODING:UTF8\n#TITLE:a\n#ARTIST:b\n#MP3:a.mp3\n#BPM:100\n#PROVIDEDBY:me\n: 0 2 0 a\nE\n",
+		},
+		{
+			name:   "downgrade with syntax",
+			text:   "#VERSION:1.1.0\n#TITLE:a\n#ARTIST:b\n#AUDIO:a.mp3\n#BPM:100\n#PROVIDEDBY:me\n: 0 2 0 a\nE\n",
+			syntax: true,
+			to:     Legacy,
+			want:   "#ENCODING:UTF8\n#TITLE:a\n#ARTIST:b\n#BPM:100\n#PROVIDEDBY:me\n#MP3:a.mp3\n: 0 2 0 a\nE\n",
+		},
+		{
+			name:   "upgrade with syntax",
+			text:   "#TITLE:a\n#ARTIST:b\n#ENCODING:UTF8\n#MP3:a.mp3\n#BPM:100\n: 0 2 0 a\nE\n",
+			syntax: true,
+			to:     Version110,
+			want:   "#VERSION:1.1.0\n#TITLE:a\n#ARTIST:b\n#BPM:100\n#AUDIO:a.mp3\n: 0 2 0 a\nE\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var opts []Option
+			if tc.syntax {
+				opts = append(opts, KeepSyntax())
+			}
+			converted, err := readSong(t, tc.text, opts...).Convert(tc.to)
+			if err != nil {
+				t.Fatalf("error converting: %v", err)
+			}
+			var buf bytes.Buffer
+			if err := (Writer{LineEnding: "\n"}).Write(&buf, converted); err != nil {
+				t.Fatalf("error writing: %v", err)
+			}
+			if got := buf.String(); got != tc.want {
+				t.Errorf("got\n%v\nwant\n%v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestConvertUnsupportedVersion(t *testing.T) {
+	song := readSong(t, "#TITLE:a\n#ARTIST:b\n#BPM:100\n: 0 2 0 a\nE\n")
+	next := LatestVersion
+	next.Minor++
+	if _, err := song.Convert(next); err == nil || !strings.Contains(err.Error(), "unsupported") {
+		t.Errorf("got error %v, want unsupported format version", err)
+	}
+}
@@ -0,0 +1,105 @@
+package usdx
+
+import (
+	"fmt"
+)
+
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Code identifies the kind of a diagnostic. Codes are stable and can be used to
+// filter or group diagnostics.
+type Code string
+
+const (
+	DuplicateTag    Code = "duplicate-tag"
+	BadNumber       Code = "bad-number"
+	BadVersion      Code = "bad-version"
+	UnknownEncoding Code = "unknown-encoding"
+	UnknownTag      Code = "unknown-tag"
+	IgnoredTag      Code = "ignored-tag"
+	MissingTag      Code = "missing-tag"
+	UnsupportedTag  Code = "unsupported-tag"
+	DeprecatedTag   Code = "deprecated-tag"
+	RemovedTag      Code = "removed-tag"
+	DecimalComma    Code = "decimal-comma"
+	BadNote         Code = "bad-note"
+	BadLineBreak    Code = "bad-line-break"
+	BadBPMChange    Code = "bad-bpm-change"
+	BadPlayer       Code = "bad-player"
+	UnknownLine     Code = "unknown-line"
+	DuetMismatch    Code = "duet-mismatch"
+	SkippedLine     Code = "skipped-line"
+	IndentedTag     Code = "indented-tag"
+	NULBytes        Code = "nul-bytes"
+	UTF16           Code = "utf-16"
+	// AmbiguousEncoding is reported if Auto could not decide between single
+	// byte encodings.
+	AmbiguousEncoding Code = "ambiguous-encoding"
+)
+
+// Diagnostic describes a problem found while reading a song. Line and Column
+// start at 1 and are 0 if the problem is not tied to a position.
+type Diagnostic struct {
+	Severity Severity
+	Code     Code
+	Line     int
+	Column   int
+	Message  string
+}
+
+func (d Diagnostic) Error() string {
+	switch {
+	case d.Line == 0:
+		return d.Message
+	case d.Column == 0:
+		return fmt.Sprintf("line %d: %v", d.Line, d.Message)
+	}
+	return fmt.Sprintf("line %d, column %d: %v", d.Line, d.Column, d.Message)
+}
+
+func warningAt(code Code, line, column int, format string, args ...interface{}) Diagnostic {
+	return Diagnostic{
+		Severity: SeverityWarning,
+		Code:     code,
+		Line:     line,
+		Column:   column,
+		Message:  fmt.Sprintf(format, args...),
+	}
+}
+
+func infoAt(code Code, line, column int, format string, args ...interface{}) Diagnostic {
+	d := warningAt(code, line, column, format, args...)
+	d.Severity = SeverityInfo
+	return d
+}
+
+// Errors returns the diagnostics as errors, for example for logging.
+func Errors(diagnostics []Diagnostic) []error {
+	errs := make([]error, len(diagnostics))
+	for i, d := range diagnostics {
+		errs[i] = d
+	}
+	return errs
+}
@@ -0,0 +1,40 @@
+package usdx
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/Patagonicus/usdx-reader/pkg/encoding"
+)
+
+// Format writes the song in the canonical form using w, even if it has a
+// Syntax. Formatting is refused if r reads the result as a different song.
+func (r Reader) Format(song Song, w Writer) ([]byte, error) {
+	song.Syntax = nil
+	var buf bytes.Buffer
+	if err := w.Write(&buf, song); err != nil {
+		return nil, err
+	}
+	formatted, _, err := r.Read(bytes.NewReader(buf.Bytes()), song.Dir, song.SourceFile)
+	if err != nil {
+		return nil, err
+	}
+	if !SameSong(song, formatted) {
+		return nil, errors.New("formatting would change the song")
+	}
+	return buf.Bytes(), nil
+}
+
+// SameSong reports whether both songs are written the same way in the
+// canonical form. The encoding and line endings of the songs are ignored.
+func SameSong(a, b Song) bool {
+	a.Syntax, b.Syntax = nil, nil
+	var bufA, bufB bytes.Buffer
+	w := Writer{
+		Encoding:   encoding.Auto,
+		LineEnding: "\n",
+	}
+	errA := w.Write(&bufA, a)
+	errB := w.Write(&bufB, b)
+	return errA == nil && errB == nil && bytes.Equal(bufA.Bytes(), bufB.Bytes())
+}
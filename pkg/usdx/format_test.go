@@ -0,0 +1,61 @@
+package usdx
+
+import (
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	text := "#ARTIST:b\r\n// comment\r\n#TITLE:a\r\n#BPM:100,0\r\n: 0 2 5 x\r\nE\r\n"
+	for _, opts := range [][]Option{nil, {KeepSyntax()}} {
+		r := NewReader(opts...)
+		song := readSong(t, text, opts...)
+		formatted, err := r.Format(song, Writer{LineEnding: "\n"})
+		if err != nil {
+			t.Fatalf("error formatting: %v", err)
+		}
+		if want := "#TITLE:a\n#ARTIST:b\n#BPM:100\n: 0 2 5 x\nE\n"; string(formatted) != want {
+			t.Errorf("got\n%q\nwant\n%q", formatted, want)
+		}
+	}
+}
+
+func TestSameSong(t *testing.T) {
+	a := readSong(t, "#TITLE:Caf\xe9\n#ARTIST:b\n#BPM:100\n: 0 2 5 x\nE\n")
+	b := readSong(t, "#ENCODING:UTF8\n#ARTIST:b\n#TITLE:Café\n#BPM:100.0\n: 0 2 5 x\nE\n")
+	c := readSong(t, "#TITLE:Cafe\n#ARTIST:b\n#BPM:100\n: 0 2 5 x\nE\n")
+	if !SameSong(a, b) {
+		t.Error("songs differ")
+	}
+	if SameSong(a, c) {
+		t.Error("songs are the same")
+	}
+}
+
+func TestSplitLines(t *testing.T) {
+	for _, tc := range []struct {
+		text string
+		want []string
+	}{
+		{"", []string{""}},
+		{"a", []string{"a"}},
+		{"a\n", []string{"a", ""}},
+		{"a\r\nb\rc\nd", []string{"a", "b", "c", "d"}},
+		{"a\n\r\nb", []string{"a", "", "b"}},
+	} {
+		if got := SplitLines(tc.text); !equalStrings(got, tc.want) {
+			t.Errorf("%q: got %q, want %q", tc.text, got, tc.want)
+		}
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
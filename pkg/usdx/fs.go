@@ -0,0 +1,51 @@
+package usdx
+
+import (
+	"io/fs"
+	"path"
+)
+
+// ReadFS reads the song file with the given name from fsys. The directory of
+// the name is used as the song's Dir.
+func (r Reader) ReadFS(fsys fs.FS, name string) (Song, []Diagnostic, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return Song{}, nil, err
+	}
+	defer f.Close()
+
+	dir, file := path.Split(name)
+	return r.Read(f, path.Clean(dir), file)
+}
+
+// ReadAllFS reads all song files below root in fsys. Song files are files
+// ending in .txt. Files that can not be read are skipped and their errors are
+// returned along with the songs that could be read.
+func (r Reader) ReadAllFS(fsys fs.FS, root string) ([]Song, map[string][]Diagnostic, []error) {
+	var songs []Song
+	diagnostics := make(map[string][]Diagnostic)
+	var errs []error
+	err := fs.WalkDir(fsys, root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			errs = append(errs, err)
+			return nil
+		}
+		if d.IsDir() || path.Ext(name) != ".txt" {
+			return nil
+		}
+		song, ds, err := r.ReadFS(fsys, name)
+		if err != nil {
+			errs = append(errs, &fs.PathError{Op: "read", Path: name, Err: err})
+			return nil
+		}
+		songs = append(songs, song)
+		if len(ds) > 0 {
+			diagnostics[name] = ds
+		}
+		return nil
+	})
+	if err != nil {
+		errs = append(errs, err)
+	}
+	return songs, diagnostics, errs
+}
@@ -0,0 +1,63 @@
+package usdx
+
+// HeaderTag is a tag as read from a file.
+type HeaderTag struct {
+	Tag string
+	// Value is the decoded text after the colon, without any trimming.
+	Value string
+	// Raw is the whole decoded line, without the line ending.
+	Raw string
+	// Line and Column are the position of the tag and of its value.
+	Line   int
+	Column int
+}
+
+// tagAliases maps tags to the tags with the same meaning.
+var tagAliases = map[string]string{
+	"P1":           "DUETSINGERP1",
+	"P2":           "DUETSINGERP2",
+	"DUETSINGERP1": "P1",
+	"DUETSINGERP2": "P2",
+}
+
+// LookupTag returns the last occurrence of a tag in the header, which is the
+// one that determines the value. P1 and DUETSINGERP1 as well as P2 and
+// DUETSINGERP2 are treated as the same tag.
+func (s Song) LookupTag(tag string) (HeaderTag, bool) {
+	alias, hasAlias := tagAliases[tag]
+	for i := len(s.Header) - 1; i >= 0; i-- {
+		if t := s.Header[i].Tag; t == tag || hasAlias && t == alias {
+			return s.Header[i], true
+		}
+	}
+	return HeaderTag{}, false
+}
+
+// HasTag reports whether the tag was present in the file, even if its value
+// was empty or could not be parsed.
+func (s Song) HasTag(tag string) bool {
+	_, ok := s.LookupTag(tag)
+	return ok
+}
+
+// DuplicateTags returns the tags of the header that have no effect because the
+// same tag follows later, in the order of the header. Only tags known to USDX
+// are considered, the same way the reader reports them as duplicates.
+func (s Song) DuplicateTags() []HeaderTag {
+	seen := make(map[string]bool)
+	var duplicates []HeaderTag
+	for i := len(s.Header) - 1; i >= 0; i-- {
+		tag := s.Header[i].Tag
+		if !usdxTags[tag] {
+			continue
+		}
+		if seen[tag] {
+			duplicates = append([]HeaderTag{s.Header[i]}, duplicates...)
+		}
+		seen[tag] = true
+		if alias, ok := tagAliases[tag]; ok {
+			seen[alias] = true
+		}
+	}
+	return duplicates
+}
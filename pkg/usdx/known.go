@@ -0,0 +1,34 @@
+package usdx
+
+// TagParser stores the value of a tag in a song.
+type TagParser func(song *Song, value string) error
+
+// KnownTag is a tag that USDX does not understand, but that is used by other
+// karaoke games or tools. Known tags are kept in CustomTags like unknown ones,
+// but do not cause a warning. If Parse is set, it is called with the value,
+// for example to fill a field of the song.
+type KnownTag struct {
+	Tag   string
+	Parse TagParser
+}
+
+// DefaultKnownTags are the known tags of a Reader. They are used by Vocaluxe,
+// Performous and UltraStar Play.
+var DefaultKnownTags = []KnownTag{
+	{"ALBUM", stringTag(func(s *Song) *string { return &s.Album })},
+	{"COMMENT", stringTag(func(s *Song) *string { return &s.Comment })},
+	{"AUTHOR", stringTag(func(s *Song) *string { return &s.Author })},
+	{"ID", stringTag(func(s *Song) *string { return &s.ID })},
+	{"P3", stringTag(func(s *Song) *string { return &s.DuetSingerP3 })},
+	{"RATING", func(s *Song, value string) (err error) {
+		s.Rating, err = parseFloat32I18n(value)
+		return err
+	}},
+}
+
+func stringTag(field func(s *Song) *string) TagParser {
+	return func(s *Song, value string) error {
+		*field(s) = value
+		return nil
+	}
+}
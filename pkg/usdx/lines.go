@@ -0,0 +1,82 @@
+package usdx
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+)
+
+// lineReader splits a file into lines. Unlike bufio.Scanner, it has no limit
+// on the length of a line. It accepts \n, \r\n and \r as line endings and
+// removes NUL bytes, reporting them as diagnostics.
+type lineReader struct {
+	data []byte
+	// line is the number of the line last returned by next, starting at 1.
+	line int
+	// ending is the first line ending found, lastEnding the one of the line
+	// last returned by next.
+	ending     string
+	lastEnding string
+
+	diagnostics []Diagnostic
+}
+
+func newLineReader(data []byte) *lineReader {
+	return &lineReader{
+		data: data,
+	}
+}
+
+func (r *lineReader) next() (string, bool) {
+	if len(r.data) == 0 {
+		return "", false
+	}
+	r.line++
+
+	end := bytes.IndexAny(r.data, "\r\n")
+	line := r.data
+	ending := ""
+	switch {
+	case end < 0:
+		r.data = nil
+	case r.data[end] == '\r' && end+1 < len(r.data) && r.data[end+1] == '\n':
+		line, r.data, ending = r.data[:end], r.data[end+2:], "\r\n"
+	default:
+		line, r.data, ending = r.data[:end], r.data[end+1:], string(r.data[end])
+	}
+	if r.ending == "" {
+		r.ending = ending
+	}
+	r.lastEnding = ending
+
+	if n := bytes.Count(line, []byte{0}); n > 0 {
+		line = bytes.ReplaceAll(line, []byte{0}, nil)
+		r.diagnostics = append(r.diagnostics, warningAt(NULBytes, r.line, 0, "removed %d NUL byte(s)", n))
+	}
+	return string(line), true
+}
+
+// isComment reports whether a line in the header is a comment. USDX has no
+// comments, but some tools write lines starting with // or ;.
+func isComment(line string) bool {
+	line = strings.TrimLeftFunc(line, unicode.IsSpace)
+	return strings.HasPrefix(line, "//") || strings.HasPrefix(line, ";")
+}
+
+// SplitLines splits text into lines without line endings. Like the reader, it
+// accepts \n, \r\n and \r as line endings. Text ending in a line ending has an
+// empty last line.
+func SplitLines(text string) []string {
+	var lines []string
+	for {
+		end := strings.IndexAny(text, "\r\n")
+		if end < 0 {
+			return append(lines, text)
+		}
+		lines = append(lines, text[:end])
+		if strings.HasPrefix(text[end:], "\r\n") {
+			end++
+		}
+		text = text[end+1:]
+	}
+}
@@ -0,0 +1,81 @@
+package usdx
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Casing describes how the values of a list are normalized.
+type Casing int
+
+const (
+	// KeepCase leaves values as they are.
+	KeepCase Casing = iota
+	// LowerCase converts values to lower case.
+	LowerCase
+	// TitleCase makes the first letter of each word upper case and all
+	// others lower case, e.g. "english" becomes "English".
+	TitleCase
+)
+
+// ListFormat describes how a tag with multiple values, like
+// "#GENRE:Pop;Rock", is split.
+type ListFormat struct {
+	// Separators holds the characters that separate values.
+	Separators string
+	Casing     Casing
+}
+
+// DefaultListFormats are the formats used by a Reader for the tags that can
+// have multiple values.
+var DefaultListFormats = map[string]ListFormat{
+	"GENRE":    {Separators: ",;", Casing: KeepCase},
+	"LANGUAGE": {Separators: ",;", Casing: TitleCase},
+	"EDITION":  {Separators: ";", Casing: KeepCase},
+	"TAGS":     {Separators: ",;", Casing: KeepCase},
+}
+
+// Split splits a value into a list. Values are trimmed and normalized, empty
+// values and values that only differ in case from earlier ones are dropped.
+func (f ListFormat) Split(s string) []string {
+	var values []string
+	seen := make(map[string]bool)
+	for _, value := range strings.FieldsFunc(s, func(r rune) bool {
+		return strings.ContainsRune(f.Separators, r)
+	}) {
+		value = f.normalize(trim(value))
+		key := strings.ToLower(value)
+		if value == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		values = append(values, value)
+	}
+	return values
+}
+
+func (f ListFormat) normalize(s string) string {
+	switch f.Casing {
+	case LowerCase:
+		return strings.ToLower(s)
+	case TitleCase:
+		startOfWord := true
+		return strings.Map(func(r rune) rune {
+			upper := startOfWord
+			startOfWord = !unicode.IsLetter(r) && r != '\''
+			if upper {
+				return unicode.ToTitle(r)
+			}
+			return unicode.ToLower(r)
+		}, s)
+	}
+	return s
+}
+
+// splitLists fills the lists of the song from the tags they are read from.
+func (s *Song) splitLists(formats map[string]ListFormat) {
+	s.Genres = formats["GENRE"].Split(s.Genre)
+	s.Languages = formats["LANGUAGE"].Split(s.Language)
+	s.Editions = formats["EDITION"].Split(s.Edition)
+	s.TagList = formats["TAGS"].Split(s.Tags)
+}
@@ -0,0 +1,34 @@
+package usdx
+
+// Logger receives log messages from the reader. Messages are followed by
+// alternating keys and values. It is implemented by *zap.SugaredLogger.
+type Logger interface {
+	Debugw(msg string, keysAndValues ...interface{})
+	Warnw(msg string, keysAndValues ...interface{})
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debugw(msg string, keysAndValues ...interface{}) {}
+func (nopLogger) Warnw(msg string, keysAndValues ...interface{})  {}
+
+// contextLogger adds keys and values to all messages.
+type contextLogger struct {
+	l       Logger
+	context []interface{}
+}
+
+func withContext(l Logger, keysAndValues ...interface{}) Logger {
+	return contextLogger{
+		l:       l,
+		context: keysAndValues,
+	}
+}
+
+func (c contextLogger) Debugw(msg string, keysAndValues ...interface{}) {
+	c.l.Debugw(msg, append(c.context[:len(c.context):len(c.context)], keysAndValues...)...)
+}
+
+func (c contextLogger) Warnw(msg string, keysAndValues ...interface{}) {
+	c.l.Warnw(msg, append(c.context[:len(c.context):len(c.context)], keysAndValues...)...)
+}
@@ -0,0 +1,90 @@
+package usdx
+
+import (
+	"fmt"
+
+	"github.com/Patagonicus/usdx-reader/pkg/encoding"
+)
+
+// MojibakeFix is a repaired field of a song.
+type MojibakeFix struct {
+	Field string
+	encoding.Mojibake
+}
+
+// FixMojibake returns a copy of the song with mojibake in text fields, custom
+// tags and syllables repaired, along with a list of the repairs. File names
+// and URLs are left alone, as they have to match the files they refer to.
+func (s Song) FixMojibake() (Song, []MojibakeFix) {
+	var fixes []MojibakeFix
+	fix := func(field string, value *string) {
+		m, ok := encoding.DetectMojibake(*value)
+		if !ok {
+			return
+		}
+		*value = m.Fixed
+		fixes = append(fixes, MojibakeFix{
+			Field:    field,
+			Mojibake: m,
+		})
+	}
+
+	fix("TITLE", &s.Title)
+	fix("ARTIST", &s.Artist)
+	fix("GENRE", &s.Genre)
+	fix("EDITION", &s.Edition)
+	fix("CREATOR", &s.Creator)
+	fix("LANGUAGE", &s.Language)
+	fix("TAGS", &s.Tags)
+	fix("PROVIDEDBY", &s.ProvidedBy)
+	fix("P1", &s.DuetSingerP1)
+	fix("P2", &s.DuetSingerP2)
+
+	// the lists and the fields of known tags are filled from fields and
+	// custom tags that are repaired, so their repairs are not reported again
+	for _, value := range []*string{&s.Album, &s.Comment, &s.Author, &s.DuetSingerP3} {
+		if m, ok := encoding.DetectMojibake(*value); ok {
+			*value = m.Fixed
+		}
+	}
+	for _, list := range []*[]string{&s.Genres, &s.Languages, &s.Editions, &s.TagList} {
+		values := append([]string(nil), *list...)
+		for i, value := range values {
+			if m, ok := encoding.DetectMojibake(value); ok {
+				values[i] = m.Fixed
+			}
+		}
+		*list = values
+	}
+
+	s.CustomTags = append([]Tag(nil), s.CustomTags...)
+	for i := range s.CustomTags {
+		fix(s.CustomTags[i].Tag, &s.CustomTags[i].Content)
+	}
+
+	tracks := make([]Track, len(s.Tracks))
+	for i, track := range s.Tracks {
+		lines := make([]Line, len(track.Lines))
+		for j, line := range track.Lines {
+			notes := append([]Note(nil), line.Notes...)
+			for k := range notes {
+				fix(fmt.Sprintf("track %d, line %d, note %d", i+1, j+1, k+1), &notes[k].Syllable)
+			}
+			lines[j] = Line{
+				Notes: notes,
+				Break: line.Break,
+			}
+		}
+		switch track.Player {
+		case 1:
+			track.Singer = s.DuetSingerP1
+		case 2:
+			track.Singer = s.DuetSingerP2
+		}
+		track.Lines = lines
+		tracks[i] = track
+	}
+	s.Tracks = tracks
+
+	return s, fixes
+}
@@ -0,0 +1,58 @@
+package usdx
+
+import (
+	"testing"
+)
+
+func TestFixMojibake(t *testing.T) {
+	song := Song{
+		Title:        "MÃ¶tley",
+		Artist:       "Crüe",
+		SoundFile:    "MÃ¶tley.mp3",
+		DuetSingerP1: "JÃ¼rgen",
+		CustomTags:   []Tag{{Tag: "COMMENT", Content: "fÃ¼r"}},
+		Tracks: []Track{{
+			Player: 1,
+			Singer: "JÃ¼rgen",
+			Lines: []Line{{
+				Notes: []Note{{Syllable: "SchÃ¶n"}, {Syllable: "ok"}},
+			}},
+		}},
+	}
+
+	fixed, fixes := song.FixMojibake()
+	if fixed.Title != "Mötley" || fixed.Artist != "Crüe" || fixed.DuetSingerP1 != "Jürgen" {
+		t.Errorf("got title %q, artist %q, P1 %q", fixed.Title, fixed.Artist, fixed.DuetSingerP1)
+	}
+	if fixed.SoundFile != song.SoundFile {
+		t.Errorf("file name changed to %q", fixed.SoundFile)
+	}
+	if got := fixed.CustomTags[0].Content; got != "für" {
+		t.Errorf("got custom tag %q", got)
+	}
+	if got := fixed.Tracks[0].Singer; got != "Jürgen" {
+		t.Errorf("got singer %q", got)
+	}
+	if got := fixed.Tracks[0].Lines[0].Notes[0].Syllable; got != "Schön" {
+		t.Errorf("got syllable %q", got)
+	}
+
+	var fields []string
+	for _, fix := range fixes {
+		fields = append(fields, fix.Field)
+	}
+	want := []string{"TITLE", "P1", "COMMENT", "track 1, line 1, note 1"}
+	if len(fields) != len(want) {
+		t.Fatalf("got fixes %q, want %q", fields, want)
+	}
+	for i := range want {
+		if fields[i] != want[i] {
+			t.Errorf("got fixes %q, want %q", fields, want)
+		}
+	}
+
+	// the song itself is not changed
+	if song.CustomTags[0].Content != "fÃ¼r" || song.Tracks[0].Lines[0].Notes[0].Syllable != "SchÃ¶n" {
+		t.Error("original song was changed")
+	}
+}
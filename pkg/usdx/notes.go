@@ -0,0 +1,401 @@
+package usdx
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type NoteKind int
+
+const (
+	NormalNote NoteKind = iota
+	GoldenNote
+	FreestyleNote
+	RapNote
+	GoldenRapNote
+)
+
+var noteKindSymbols = map[NoteKind]byte{
+	NormalNote:    ':',
+	GoldenNote:    '*',
+	FreestyleNote: 'F',
+	RapNote:       'R',
+	GoldenRapNote: 'G',
+}
+
+// Symbol returns the character used to mark notes of this kind in a song file.
+func (k NoteKind) Symbol() byte {
+	return noteKindSymbols[k]
+}
+
+func (k NoteKind) String() string {
+	switch k {
+	case NormalNote:
+		return "normal"
+	case GoldenNote:
+		return "golden"
+	case FreestyleNote:
+		return "freestyle"
+	case RapNote:
+		return "rap"
+	case GoldenRapNote:
+		return "golden rap"
+	}
+	return fmt.Sprintf("NoteKind(%d)", int(k))
+}
+
+func noteKindFromSymbol(c byte) (NoteKind, bool) {
+	for kind, symbol := range noteKindSymbols {
+		if symbol == c {
+			return kind, true
+		}
+	}
+	return 0, false
+}
+
+type Note struct {
+	Kind     NoteKind
+	Start    int
+	Length   int
+	Pitch    int
+	Syllable string
+	// Line is the line of the file the note was read from, or 0.
+	Line int
+}
+
+func (n Note) String() string {
+	return fmt.Sprintf("%c %d %d %d %s", n.Kind.Symbol(), n.Start, n.Length, n.Pitch, n.Syllable)
+}
+
+var pitchNames = []string{"C", "C#", "D", "D#", "E", "F", "F#", "G", "G#", "A", "A#", "B"}
+
+// PitchName returns the name of a pitch as used in song files, where 0 is the
+// middle C, C4.
+func PitchName(pitch int) string {
+	octave := 4 + pitch/12
+	i := pitch % 12
+	if i < 0 {
+		i += 12
+		octave--
+	}
+	return fmt.Sprintf("%v%d", pitchNames[i], octave)
+}
+
+type LineBreak struct {
+	Beat int
+	// Offset is the second number of a line break. It is only used by songs
+	// with #RELATIVE set, where it moves the base for the following notes.
+	Offset int
+	// Line is the line of the file the line break was read from, or 0.
+	Line int
+}
+
+// Line is a sequence of notes that is displayed together. Break is the line
+// break ending it and is nil for the last line of a track.
+type Line struct {
+	Notes []Note
+	Break *LineBreak
+}
+
+// Track holds the notes sung by one singer. Player is the number given by the
+// P marker of a duet, or 0 for songs without markers. Singer is the name set
+// by the corresponding #P1, #P2 or #P3 tag.
+type Track struct {
+	Player int
+	Singer string
+	Lines  []Line
+}
+
+type notesParser struct {
+	song  *Song
+	track int
+	line  int
+	done  bool
+	// bases holds the current base of each track for relative songs
+	bases []int
+	// canonical is the last line parsed, as NoteLines would write it. It is
+	// empty if the line was skipped.
+	canonical string
+
+	diagnostics []Diagnostic
+}
+
+func newNotesParser(song *Song) *notesParser {
+	return &notesParser{
+		song:  song,
+		track: -1,
+		line:  -1,
+	}
+}
+
+func (p *notesParser) warn(code Code, lineNo int, format string, args ...interface{}) {
+	p.diagnostics = append(p.diagnostics, warningAt(code, lineNo, 1, format, args...))
+}
+
+// parse handles a single line of the note section, lineNo being its position
+// in the file. It returns false once the E marker has been seen; all following
+// lines are ignored.
+func (p *notesParser) parse(lineNo int, line string) bool {
+	p.canonical = ""
+	if p.done {
+		return false
+	}
+
+	line = strings.TrimRight(line, "\r")
+	if trim(line) == "" {
+		return true
+	}
+
+	switch c := line[0]; c {
+	case 'E':
+		p.done = true
+		p.song.Terminated = true
+		p.canonical = "E"
+		return false
+	case 'P':
+		player, err := strconv.Atoi(trim(line[1:]))
+		if err != nil || player < 1 {
+			p.warn(BadPlayer, lineNo, "invalid player marker '%v'", line)
+			return true
+		}
+		p.selectTrack(player)
+		p.canonical = fmt.Sprintf("P%d", player)
+	case 'B':
+		fields := strings.Fields(line[1:])
+		if len(fields) != 2 {
+			p.warn(BadBPMChange, lineNo, "invalid BPM change '%v'", line)
+			return true
+		}
+		beat, err := strconv.Atoi(fields[0])
+		if err != nil {
+			p.warn(BadBPMChange, lineNo, "invalid BPM change '%v': %v", line, err)
+			return true
+		}
+		bpm, err := parseFloat32I18n(strings.Replace(fields[1], ",", ".", -1))
+		if err != nil {
+			p.warn(BadBPMChange, lineNo, "invalid BPM change '%v': %v", line, err)
+			return true
+		}
+		p.canonical = formatBPMChange(BPMChange{beat, bpm}, 0, false)
+		// like USDX, use the base of the first track for relative songs
+		if p.song.Relative && len(p.bases) > 0 {
+			beat += p.bases[0]
+		}
+		p.song.BPMChanges = append(p.song.BPMChanges, BPMChange{
+			Beat: beat,
+			BPM:  bpm,
+		})
+	case '-':
+		fields := strings.Fields(line[1:])
+		if len(fields) < 1 || len(fields) > 2 {
+			p.warn(BadLineBreak, lineNo, "invalid line break '%v'", line)
+			return true
+		}
+		lineBreak := LineBreak{
+			Line: lineNo,
+		}
+		var err error
+		lineBreak.Beat, err = strconv.Atoi(fields[0])
+		// USDX ignores the second number unless the song is relative
+		if err == nil && len(fields) == 2 && p.song.Relative {
+			lineBreak.Offset, err = strconv.Atoi(fields[1])
+		}
+		if err != nil {
+			p.warn(BadLineBreak, lineNo, "invalid line break '%v': %v", line, err)
+			return true
+		}
+		p.currentLine().Break = &lineBreak
+		p.bases[p.track] += lineBreak.Offset
+		p.line = -1
+		p.canonical = lineBreak.format(p.song.Relative)
+	default:
+		kind, ok := noteKindFromSymbol(c)
+		if !ok {
+			p.warn(UnknownLine, lineNo, "unknown line '%v'", line)
+			return true
+		}
+		note, err := parseNote(kind, line[1:])
+		if err != nil {
+			p.warn(BadNote, lineNo, "invalid note '%v': %v", line, err)
+			return true
+		}
+		note.Line = lineNo
+		current := p.currentLine()
+		current.Notes = append(current.Notes, note)
+		p.canonical = note.String()
+	}
+	return true
+}
+
+// selectTrack switches to the track of the given player, creating it if
+// necessary. Notes continue on the last line of that track unless it already
+// ended with a line break.
+func (p *notesParser) selectTrack(player int) {
+	p.track = -1
+	for i, track := range p.song.Tracks {
+		if track.Player == player {
+			p.track = i
+		}
+	}
+	if p.track < 0 {
+		p.song.Tracks = append(p.song.Tracks, Track{
+			Player: player,
+		})
+		p.bases = append(p.bases, 0)
+		p.track = len(p.song.Tracks) - 1
+	}
+
+	p.line = -1
+	lines := p.song.Tracks[p.track].Lines
+	if len(lines) > 0 && lines[len(lines)-1].Break == nil {
+		p.line = len(lines) - 1
+	}
+}
+
+func (p *notesParser) currentLine() *Line {
+	if p.track < 0 {
+		p.song.Tracks = append(p.song.Tracks, Track{})
+		p.bases = append(p.bases, 0)
+		p.track = len(p.song.Tracks) - 1
+	}
+	track := &p.song.Tracks[p.track]
+	if p.line < 0 {
+		track.Lines = append(track.Lines, Line{})
+		p.line = len(track.Lines) - 1
+	}
+	return &track.Lines[p.line]
+}
+
+// finish assigns the duet singers to their tracks and checks that the tracks
+// match the singers declared in the header. It returns all diagnostics found
+// while parsing.
+func (p *notesParser) finish() []Diagnostic {
+	for i := range p.song.Tracks {
+		track := &p.song.Tracks[i]
+		switch track.Player {
+		case 1:
+			track.Singer = p.song.DuetSingerP1
+		case 2:
+			track.Singer = p.song.DuetSingerP2
+		case 3:
+			track.Singer = p.song.DuetSingerP3
+		}
+	}
+
+	declared := p.song.DuetSingerP1 != "" || p.song.DuetSingerP2 != "" || p.song.DuetSingerP3 != ""
+	if declared && len(p.song.Tracks) < 2 {
+		p.diagnostics = append(p.diagnostics, warningAt(DuetMismatch, 0, 0, "song declares duet singers but has %v track(s)", len(p.song.Tracks)))
+	}
+	if !declared && len(p.song.Tracks) > 1 {
+		p.diagnostics = append(p.diagnostics, warningAt(DuetMismatch, 0, 0, "song has %v tracks but declares no duet singers", len(p.song.Tracks)))
+	}
+	return p.diagnostics
+}
+
+// parseNote parses the part of a note line after the kind symbol. The syllable
+// is everything after the single separator following the pitch, so leading
+// and trailing spaces are kept.
+func parseNote(kind NoteKind, s string) (Note, error) {
+	note := Note{
+		Kind: kind,
+	}
+	for _, field := range []*int{&note.Start, &note.Length, &note.Pitch} {
+		s = strings.TrimLeftFunc(s, unicode.IsSpace)
+		end := strings.IndexFunc(s, unicode.IsSpace)
+		if end < 0 {
+			end = len(s)
+		}
+		if end == 0 {
+			return note, errors.New("missing number")
+		}
+		var err error
+		*field, err = strconv.Atoi(s[:end])
+		if err != nil {
+			return note, err
+		}
+		s = s[end:]
+	}
+	// only the separator is removed, the syllable can start with a space
+	_, size := utf8.DecodeRuneInString(s)
+	note.Syllable = s[size:]
+	return note, nil
+}
+
+// ParseNotes parses the note section of a song, i.e. all lines following the
+// header, and stores the result in song. Line numbers of the diagnostics are
+// relative to the first line.
+func ParseNotes(song *Song, lines []string) []Diagnostic {
+	p := newNotesParser(song)
+	for i, line := range lines {
+		if !p.parse(i+1, line) {
+			break
+		}
+	}
+	return p.finish()
+}
+
+// IsDuet reports whether the song has notes for more than one singer.
+func (s Song) IsDuet() bool {
+	return len(s.Tracks) > 1
+}
+
+// NoteLines returns the note section of the song in the format used by song
+// files. BPM changes are written to the first track, in front of the first
+// note that starts at or after them.
+func (s Song) NoteLines() []string {
+	var lines []string
+	changes := s.BPMChanges
+	for i, track := range s.Tracks {
+		if track.Player > 0 {
+			lines = append(lines, fmt.Sprintf("P%d", track.Player))
+		}
+		base := 0
+		for _, line := range track.Lines {
+			for _, note := range line.Notes {
+				for i == 0 && len(changes) > 0 && changes[0].Beat <= base+note.Start {
+					lines = append(lines, formatBPMChange(changes[0], base, s.Relative))
+					changes = changes[1:]
+				}
+				lines = append(lines, note.String())
+			}
+			if line.Break != nil {
+				lines = append(lines, line.Break.format(s.Relative))
+				if s.Relative {
+					base += line.Break.Offset
+				}
+			}
+		}
+		if i == 0 {
+			for _, change := range changes {
+				lines = append(lines, formatBPMChange(change, base, s.Relative))
+			}
+			changes = nil
+		}
+	}
+	for _, change := range changes {
+		lines = append(lines, formatBPMChange(change, 0, s.Relative))
+	}
+	if s.Terminated {
+		lines = append(lines, "E")
+	}
+	return lines
+}
+
+func (b LineBreak) format(relative bool) string {
+	if relative {
+		return fmt.Sprintf("- %d %d", b.Beat, b.Offset)
+	}
+	return fmt.Sprintf("- %d", b.Beat)
+}
+
+func formatBPMChange(change BPMChange, base int, relative bool) string {
+	beat := change.Beat
+	if relative {
+		beat -= base
+	}
+	return fmt.Sprintf("B %d %v", beat, formatFloat32(change.BPM))
+}
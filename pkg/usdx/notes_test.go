@@ -0,0 +1,167 @@
+package usdx
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestParseNote(t *testing.T) {
+	for _, tc := range []struct {
+		in      string
+		want    Note
+		wantErr bool
+	}{
+		{in: " 0 1 2 word", want: Note{Start: 0, Length: 1, Pitch: 2, Syllable: "word"}},
+		{in: " 0 1 2  word", want: Note{Start: 0, Length: 1, Pitch: 2, Syllable: " word"}},
+		{in: " 0 1 2 word ", want: Note{Start: 0, Length: 1, Pitch: 2, Syllable: "word "}},
+		{in: " 0 1 -3 ~", want: Note{Start: 0, Length: 1, Pitch: -3, Syllable: "~"}},
+		{in: "  12\t4   5 x", want: Note{Start: 12, Length: 4, Pitch: 5, Syllable: "x"}},
+		{in: " 0 1 2", want: Note{Start: 0, Length: 1, Pitch: 2}},
+		{in: " 0 1 2 word", want: Note{Start: 0, Length: 1, Pitch: 2, Syllable: "word"}},
+		{in: " 0 1 2\u3000単語", want: Note{Start: 0, Length: 1, Pitch: 2, Syllable: "単語"}},
+		{in: " 0 1", wantErr: true},
+		{in: " a 1 2 x", wantErr: true},
+	} {
+		got, err := parseNote(NormalNote, tc.in)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("parseNote(%q): expected an error", tc.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseNote(%q): %v", tc.in, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("parseNote(%q) = %+v, want %+v", tc.in, got, tc.want)
+		}
+		if !utf8.ValidString(got.Syllable) {
+			t.Errorf("parseNote(%q): syllable %q is not valid UTF-8", tc.in, got.Syllable)
+		}
+	}
+}
+
+func TestDuetSingers(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		text     string
+		singers  []string
+		mismatch bool
+	}{
+		{
+			name:    "duet",
+			text:    "#TITLE:a\n#ARTIST:b\n#BPM:100\n#P1:x\n#P2:y\nP1\n: 0 2 0 a\nP2\n: 0 2 0 b\nE\n",
+			singers: []string{"x", "y"},
+		},
+		{
+			name:    "three singers",
+			text:    "#TITLE:a\n#ARTIST:b\n#BPM:100\n#P1:x\n#P2:y\n#P3:z\nP1\n: 0 2 0 a\nP2\n: 0 2 0 b\nP3\n: 0 2 0 c\nE\n",
+			singers: []string{"x", "y", "z"},
+		},
+		{
+			name:     "only the third singer declared",
+			text:     "#TITLE:a\n#ARTIST:b\n#BPM:100\n#P3:z\n: 0 2 0 a\nE\n",
+			singers:  []string{""},
+			mismatch: true,
+		},
+		{
+			name:     "no singers declared",
+			text:     "#TITLE:a\n#ARTIST:b\n#BPM:100\nP1\n: 0 2 0 a\nP2\n: 0 2 0 b\nE\n",
+			singers:  []string{"", ""},
+			mismatch: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			song, diagnostics, err := NewReader().Read(strings.NewReader(tc.text), "", "test.txt")
+			if err != nil {
+				t.Fatalf("error reading song: %v", err)
+			}
+			var singers []string
+			for _, track := range song.Tracks {
+				singers = append(singers, track.Singer)
+			}
+			if !reflect.DeepEqual(singers, tc.singers) {
+				t.Errorf("expected singers %q, got %q", tc.singers, singers)
+			}
+			mismatch := false
+			for _, d := range diagnostics {
+				if d.Code == DuetMismatch {
+					mismatch = true
+				}
+			}
+			if mismatch != tc.mismatch {
+				t.Errorf("expected a duet mismatch: %v, got %v", tc.mismatch, diagnostics)
+			}
+		})
+	}
+}
+
+func TestDuets(t *testing.T) {
+	const header = "#TITLE:a\n#ARTIST:b\n#BPM:100\n#P1:x\n#P2:y\n"
+	for _, tc := range []struct {
+		name    string
+		text    string
+		players []int
+		// starts holds the absolute starts of the notes of each line of
+		// each track
+		starts     [][][]int
+		bpmChanges []BPMChange
+	}{
+		{
+			name:    "switching back and forth",
+			text:    header + "P1\n: 0 2 0 a\n- 4\nP2\n: 0 2 0 b\n- 4\nP1\n: 6 2 0 c\nP2\n: 6 2 0 d\n- 8\nP1\n- 8\n: 10 2 0 e\nE\n",
+			players: []int{1, 2},
+			starts:  [][][]int{{{0}, {6}, {10}}, {{0}, {6}}},
+		},
+		{
+			name:    "unfinished line",
+			text:    header + "P1\n: 0 2 0 a\nP2\n: 0 2 0 b\nP1\n: 2 2 0 c\n- 4\n: 6 2 0 d\nP2\n: 2 2 0 e\nE\n",
+			players: []int{1, 2},
+			starts:  [][][]int{{{0, 2}, {6}}, {{0, 2}}},
+		},
+		{
+			name:    "second player first",
+			text:    header + "P2\n: 0 2 0 a\nP1\n: 2 2 0 b\nE\n",
+			players: []int{2, 1},
+			starts:  [][][]int{{{0}}, {{2}}},
+		},
+		{
+			name:       "relative",
+			text:       header + "#RELATIVE:yes\nP1\n: 0 2 0 a\n- 4 10\nP2\n: 0 2 0 b\n- 4 20\nB 2 200\nP1\n: 0 2 0 c\nP2\n: 0 2 0 d\n- 4 5\n: 0 2 0 e\nE\n",
+			players:    []int{1, 2},
+			starts:     [][][]int{{{0}, {10}}, {{0}, {20}, {25}}},
+			bpmChanges: []BPMChange{{Beat: 12, BPM: 200}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			song := readSong(t, tc.text)
+			if !reflect.DeepEqual(song.BPMChanges, tc.bpmChanges) {
+				t.Errorf("expected BPM changes %v, got %v", tc.bpmChanges, song.BPMChanges)
+			}
+			song = song.ToAbsolute()
+			var players []int
+			var starts [][][]int
+			for _, track := range song.Tracks {
+				players = append(players, track.Player)
+				var lines [][]int
+				for _, line := range track.Lines {
+					var notes []int
+					for _, note := range line.Notes {
+						notes = append(notes, note.Start)
+					}
+					lines = append(lines, notes)
+				}
+				starts = append(starts, lines)
+			}
+			if !reflect.DeepEqual(players, tc.players) {
+				t.Errorf("expected players %v, got %v", tc.players, players)
+			}
+			if !reflect.DeepEqual(starts, tc.starts) {
+				t.Errorf("expected starts %v, got %v", tc.starts, starts)
+			}
+		})
+	}
+}
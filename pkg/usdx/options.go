@@ -0,0 +1,92 @@
+package usdx
+
+import (
+	"github.com/Patagonicus/usdx-reader/pkg/encoding"
+	"go.uber.org/zap"
+)
+
+// Option configures a Reader.
+type Option func(r *Reader)
+
+// WithLogger sets the logger used by the reader. By default, nothing is logged.
+func WithLogger(l Logger) Option {
+	return func(r *Reader) {
+		if l == nil {
+			l = nopLogger{}
+		}
+		r.l = l
+	}
+}
+
+// WithZap logs to a zap logger. A nil logger logs nothing, like
+// WithLogger(nil).
+func WithZap(l *zap.Logger) Option {
+	if l == nil {
+		return WithLogger(nil)
+	}
+	return WithLogger(l.Sugar())
+}
+
+// WithAutoFallback sets the encoding used by Auto for values that are not
+// valid UTF-8. The default is CP1250, like in USDX.
+func WithAutoFallback(fallback encoding.Decoder) Option {
+	return func(r *Reader) {
+		auto := encoding.NewAuto(fallback)
+		if r.defaultEncoding.Name() == auto.Name() {
+			r.defaultEncoding = auto
+		}
+		r.encodings[auto.Name()] = auto
+	}
+}
+
+// WithEncodings registers additional encodings that can be selected with the
+// #ENCODING tag. Encodings replace earlier ones with the same name.
+func WithEncodings(encodings ...Encoding) Option {
+	return func(r *Reader) {
+		for _, enc := range encodings {
+			r.encodings[enc.Name()] = enc
+		}
+	}
+}
+
+// WithDefaultEncoding sets the encoding used for files without a byte order
+// mark or an #ENCODING tag. The default is encoding.Auto.
+func WithDefaultEncoding(enc Encoding) Option {
+	return func(r *Reader) {
+		r.defaultEncoding = enc
+	}
+}
+
+// WithListFormat sets how the values of #GENRE, #LANGUAGE, #EDITION or #TAGS
+// are split, see DefaultListFormats.
+func WithListFormat(tag string, format ListFormat) Option {
+	return func(r *Reader) {
+		r.listFormats[tag] = format
+	}
+}
+
+// WithKnownTags adds tags that are not reported as unknown, see KnownTag. Tags
+// replace earlier ones with the same name.
+func WithKnownTags(tags ...KnownTag) Option {
+	return func(r *Reader) {
+		for _, tag := range tags {
+			r.knownTags[tag.Tag] = tag
+		}
+	}
+}
+
+// KeepSyntax makes the reader keep all lines of a file in Song.Syntax, so that
+// the writer can change only what was edited.
+func KeepSyntax() Option {
+	return func(r *Reader) {
+		r.keepSyntax = true
+	}
+}
+
+// Strict makes Read fail if any warning is found. The song and all
+// diagnostics are still returned.
+func Strict() Option {
+	return func(r *Reader) {
+		r.strict = true
+	}
+}
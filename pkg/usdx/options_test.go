@@ -0,0 +1,10 @@
+package usdx
+
+import "testing"
+
+func TestWithZapNil(t *testing.T) {
+	song := readSong(t, "#TITLE:a\n#ARTIST:b\n#BPM:100\n#UNKNOWN:x\n: 0 2 0 a\nE\n", WithZap(nil))
+	if song.Title != "a" {
+		t.Errorf("expected title 'a', got %q", song.Title)
+	}
+}
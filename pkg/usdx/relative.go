@@ -0,0 +1,43 @@
+package usdx
+
+// ToAbsolute returns a copy of the song with all beats converted to absolute
+// beats. In songs with #RELATIVE set, the beats of a line are relative to a
+// base that starts at 0 for every track. The first number of a line break is
+// relative to the current base as well, the second number is added to the base
+// for all following lines. #MEDLEYSTARTBEAT and #MEDLEYENDBEAT are not part
+// of a line, so they are relative to the base at the start of the song, which
+// is 0; they are kept as they are. Songs that are not relative are returned
+// unchanged.
+func (s Song) ToAbsolute() Song {
+	if !s.Relative {
+		return s
+	}
+
+	tracks := make([]Track, len(s.Tracks))
+	for i, track := range s.Tracks {
+		base := 0
+		lines := make([]Line, len(track.Lines))
+		for j, line := range track.Lines {
+			notes := make([]Note, len(line.Notes))
+			for k, note := range line.Notes {
+				note.Start += base
+				notes[k] = note
+			}
+			lines[j].Notes = notes
+
+			if line.Break != nil {
+				lines[j].Break = &LineBreak{
+					Beat: base + line.Break.Beat,
+					Line: line.Break.Line,
+				}
+				base += line.Break.Offset
+			}
+		}
+		track.Lines = lines
+		tracks[i] = track
+	}
+
+	s.Tracks = tracks
+	s.Relative = false
+	return s
+}
@@ -0,0 +1,99 @@
+package usdx
+
+import (
+	"strings"
+	"testing"
+)
+
+func readSong(t *testing.T, text string, opts ...Option) Song {
+	t.Helper()
+	song, _, err := NewReader(opts...).Read(strings.NewReader(text), "", "test.txt")
+	if err != nil {
+		t.Fatalf("error reading song: %v", err)
+	}
+	return song
+}
+
+func TestToAbsolute(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		text        string
+		starts      []int
+		breaks      []int
+		medleyStart int
+		medleyEnd   int
+	}{
+		{
+			name:   "absolute",
+			text:   "#TITLE:a\n#ARTIST:b\n#BPM:100\n: 0 2 0 a\n: 2 2 0 b\n- 4\n: 6 2 0 c\nE\n",
+			starts: []int{0, 2, 6},
+			breaks: []int{4},
+		},
+		{
+			name:   "relative",
+			text:   "#TITLE:a\n#ARTIST:b\n#BPM:100\n#RELATIVE:yes\n: 0 2 0 a\n: 2 2 0 b\n- 4 6\n: 0 2 0 c\n- 2 4\n: 1 2 0 d\nE\n",
+			starts: []int{0, 2, 6, 11},
+			breaks: []int{4, 8},
+		},
+		{
+			name:        "relative with medley",
+			text:        "#TITLE:a\n#ARTIST:b\n#BPM:100\n#RELATIVE:yes\n#MEDLEYSTARTBEAT:6\n#MEDLEYENDBEAT:13\n: 0 2 0 a\n- 4 6\n: 0 2 0 c\n- 2 4\n: 1 2 0 d\nE\n",
+			starts:      []int{0, 6, 11},
+			breaks:      []int{4, 8},
+			medleyStart: 6,
+			medleyEnd:   13,
+		},
+		{
+			name:        "medley before relative",
+			text:        "#TITLE:a\n#ARTIST:b\n#BPM:100\n#MEDLEYSTARTBEAT:6\n#MEDLEYENDBEAT:13\n#RELATIVE:yes\n: 0 2 0 a\n- 4 6\n: 0 2 0 c\n- 2 4\n: 1 2 0 d\nE\n",
+			starts:      []int{0, 6, 11},
+			breaks:      []int{4, 8},
+			medleyStart: 6,
+			medleyEnd:   13,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			song := readSong(t, tc.text)
+			abs := song.ToAbsolute()
+			if abs.Relative {
+				t.Error("song is still relative")
+			}
+
+			var starts, breaks []int
+			for _, line := range abs.Tracks[0].Lines {
+				for _, note := range line.Notes {
+					starts = append(starts, note.Start)
+				}
+				if line.Break != nil {
+					breaks = append(breaks, line.Break.Beat)
+				}
+			}
+			if !equalInts(starts, tc.starts) {
+				t.Errorf("got starts %v, want %v", starts, tc.starts)
+			}
+			if !equalInts(breaks, tc.breaks) {
+				t.Errorf("got breaks %v, want %v", breaks, tc.breaks)
+			}
+			if abs.MedleyStartBeat != tc.medleyStart || abs.MedleyEndBeat != tc.medleyEnd {
+				t.Errorf("got medley %d to %d, want %d to %d", abs.MedleyStartBeat, abs.MedleyEndBeat, tc.medleyStart, tc.medleyEnd)
+			}
+
+			// the original song must not change
+			if song.Relative && song.Tracks[0].Lines[1].Notes[0].Start != 0 {
+				t.Error("ToAbsolute changed the original song")
+			}
+		})
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
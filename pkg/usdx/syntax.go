@@ -0,0 +1,315 @@
+package usdx
+
+import (
+	"strings"
+
+	"github.com/Patagonicus/usdx-reader/pkg/diff"
+)
+
+// LineKind is the kind of a line in a Syntax.
+type LineKind int
+
+const (
+	TagLine LineKind = iota
+	BlankLine
+	// CommentLine is a comment in the header, see isComment.
+	CommentLine
+	// NoteLine is a line of the note section that was understood: a note, a
+	// line break, a P marker or a BPM change.
+	NoteLine
+	EndLine
+	// InvalidLine is a line that was skipped with a diagnostic.
+	InvalidLine
+	// TrailingLine is a line after the E marker, which USDX ignores.
+	TrailingLine
+)
+
+// SyntaxLine is a line of a song file as it was read.
+type SyntaxLine struct {
+	Kind LineKind
+	// Text is the decoded line without its line ending.
+	Text   string
+	Ending string
+	// Tag and Value are set for tag lines.
+	Tag   string
+	Value string
+
+	// canonical is the line as NoteLines would write it, for lines of the
+	// note section that were understood
+	canonical string
+}
+
+// Syntax holds every line of a song file. A song with a Syntax is written by
+// only changing the lines whose values changed, so that editing a tag results
+// in a minimal diff. Lines holds the lines in the order of the file, so line n
+// is Lines[n-1]. See KeepSyntax.
+type Syntax struct {
+	Lines []SyntaxLine
+
+	// tags and notes are the header tags and note lines as the writer would
+	// have written them for the song when it was read
+	tags  []Tag
+	notes []string
+	// version and encoding are the format version and the encoding the file
+	// was read with
+	version  Version
+	encoding Encoding
+}
+
+// headerEnd returns the index of the first line after the last tag.
+func (s *Syntax) headerEnd() int {
+	for i := len(s.Lines) - 1; i >= 0; i-- {
+		if s.Lines[i].Kind == TagLine {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// lineWriter writes lines, adding a line ending where a line without one
+// is followed by another.
+type lineWriter struct {
+	enc        Encoding
+	lineEnding string
+	// override replaces the line endings of all lines if it is set
+	override string
+	lines    []string
+	missing  bool
+}
+
+func (w *lineWriter) write(text, ending string) error {
+	if w.override != "" {
+		ending = w.override
+	}
+	if w.missing {
+		text = w.lineEnding + text
+	}
+	encoded, err := w.enc.Encode(text + ending)
+	if err != nil {
+		return err
+	}
+	w.lines = append(w.lines, encoded)
+	w.missing = ending == ""
+	return nil
+}
+
+func (w *lineWriter) writeNew(text string) error {
+	return w.write(text, w.lineEnding)
+}
+
+// writeSyntax writes the song using its syntax tree. Tag lines are rewritten
+// if the values of their tag changed, keeping everything up to the value.
+// Tags that were not in the file are added after the last tag. Lines of the
+// note section are matched to the notes of the song, so that only changed
+// lines are replaced. If encodingTag is nil, #ENCODING tags are left alone.
+func (s Song) writeSyntax(w *lineWriter, tags []Tag, encodingTag *Tag) error {
+	syntax := s.Syntax
+	oldValues := groupTags(syntax.tags)
+	newValues := groupTags(tags)
+	if encodingTag != nil {
+		for _, line := range syntax.Lines {
+			if line.Kind == TagLine && line.Tag == "ENCODING" {
+				oldValues["ENCODING"] = append(oldValues["ENCODING"], trim(line.Value))
+			}
+		}
+		if encodingTag.Content != "" {
+			newValues["ENCODING"] = []string{encodingTag.Content}
+			tags = append([]Tag{*encodingTag}, tags...)
+		}
+	}
+
+	// key returns the name a tag of the file is written as, e.g. P1 for
+	// DUETSINGERP1 in newer format versions
+	key := func(tag string) string {
+		alias, ok := tagAliases[tag]
+		if _, known := oldValues[tag]; known || !ok {
+			return tag
+		}
+		if _, known := oldValues[alias]; known {
+			return alias
+		}
+		return tag
+	}
+
+	counts := make(map[string]int)
+	for _, line := range syntax.Lines {
+		if line.Kind == TagLine {
+			counts[key(line.Tag)]++
+		}
+	}
+	// the format version determines how values are written, e.g. whether
+	// decimal commas are allowed, so all tags are written again if it changed
+	changed := func(tag string) bool {
+		return s.Version != syntax.version || !equalValues(oldValues[tag], newValues[tag])
+	}
+
+	// #ENCODING and #VERSION go in front of the tags that are not in the file
+	// yet, as they apply to the tags following them
+	var first, last []string
+	for _, tag := range tags {
+		if counts[tag.Tag] > 0 || !changed(tag.Tag) || contains(first, tag.Tag) || contains(last, tag.Tag) {
+			continue
+		}
+		if tag.Tag == "ENCODING" || tag.Tag == "VERSION" {
+			first = append(first, tag.Tag)
+		} else {
+			last = append(last, tag.Tag)
+		}
+	}
+	if err := writeNewTags(w, first, newValues); err != nil {
+		return err
+	}
+
+	end := syntax.headerEnd()
+	seen := make(map[string]int)
+	for i, line := range syntax.Lines[:end] {
+		tag := key(line.Tag)
+		if line.Kind != TagLine || !changed(tag) {
+			if err := w.write(line.Text, line.Ending); err != nil {
+				return err
+			}
+		} else {
+			// the last values replace the last lines, earlier lines are
+			// dropped and additional values follow the last line
+			n := seen[tag]
+			seen[tag]++
+			values := newValues[tag]
+			if k := n - (counts[tag] - len(values)); k >= 0 {
+				if err := w.write(line.prefix()+values[k], line.Ending); err != nil {
+					return err
+				}
+			}
+			if n == counts[tag]-1 {
+				for k := counts[tag]; k < len(values); k++ {
+					if err := w.writeNew(line.prefix() + values[k]); err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		if i == end-1 {
+			if err := writeNewTags(w, last, newValues); err != nil {
+				return err
+			}
+		}
+	}
+	if end == 0 {
+		if err := writeNewTags(w, last, newValues); err != nil {
+			return err
+		}
+	}
+
+	return s.writeSyntaxNotes(w, syntax.Lines[end:])
+}
+
+func writeNewTags(w *lineWriter, tags []string, values map[string][]string) error {
+	for _, tag := range tags {
+		for _, value := range values[tag] {
+			if err := w.writeNew(formatTag(tag, value)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeSyntaxNotes writes the note section. If the notes did not change, it
+// is written as it was read. Otherwise, lines that were not understood stay
+// in front of the line following them.
+func (s Song) writeSyntaxNotes(w *lineWriter, lines []SyntaxLine) error {
+	if equalValues(s.Syntax.notes, s.NoteLines()) {
+		for _, line := range lines {
+			if err := w.write(line.Text, line.Ending); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var old []string
+	for _, line := range lines {
+		if line.canonical != "" {
+			old = append(old, line.canonical)
+		}
+	}
+
+	i := 0
+	// next writes the lines up to the next line of the note section and
+	// returns it
+	next := func() (SyntaxLine, error) {
+		for ; lines[i].canonical == ""; i++ {
+			if err := w.write(lines[i].Text, lines[i].Ending); err != nil {
+				return SyntaxLine{}, err
+			}
+		}
+		i++
+		return lines[i-1], nil
+	}
+	for _, edit := range diff.Compute(old, s.NoteLines()) {
+		switch edit.Op {
+		case diff.Equal:
+			line, err := next()
+			if err != nil {
+				return err
+			}
+			if err := w.write(line.Text, line.Ending); err != nil {
+				return err
+			}
+		case diff.Remove:
+			if _, err := next(); err != nil {
+				return err
+			}
+		case diff.Insert:
+			if err := w.writeNew(edit.Line); err != nil {
+				return err
+			}
+		}
+	}
+	for _, line := range lines[i:] {
+		if line.Kind == TrailingLine && !s.Terminated {
+			continue
+		}
+		if err := w.write(line.Text, line.Ending); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prefix returns the part of a tag line in front of its value.
+func (l SyntaxLine) prefix() string {
+	if !strings.HasSuffix(l.Text, l.Value) {
+		return formatTag(l.Tag, "")
+	}
+	return l.Text[:len(l.Text)-len(l.Value)]
+}
+
+func groupTags(tags []Tag) map[string][]string {
+	values := make(map[string][]string)
+	for _, tag := range tags {
+		values[tag.Tag] = append(values[tag.Tag], tag.Content)
+	}
+	return values
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func equalValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,95 @@
+package usdx
+
+import (
+	"testing"
+)
+
+// Songs read with KeepSyntax are written byte for byte as they were read.
+func TestWriteSyntaxRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		text string
+	}{
+		{name: "canonical", text: "#TITLE:a\n#ARTIST:b\n#BPM:100\n: 0 2 5 x\nE\n"},
+		{name: "tag order and case", text: "#artist:b\n#Title:a\n#BPM:100,50\n#GAP:0\n: 0 2 5 x\nE\n"},
+		{name: "comments and blank lines", text: "// comment\n#TITLE:a\n\n#ARTIST:b\n; other\n#BPM:100\n: 0 2 5 x\n// note comment\n\n: 2 2 5 y\nE\n"},
+		{name: "indented tag", text: "#TITLE:a\n  #ARTIST:b\n#BPM:100\n: 0 2 5 x\nE\n"},
+		{name: "spacing", text: "#TITLE: a \n#ARTIST:b\n#BPM:100\n:  0  2 5  x\n-  4\n*\t6 2 5 y\nE\n"},
+		{name: "line endings", text: "#TITLE:a\r\n#ARTIST:b\n#BPM:100\r: 0 2 5 x\r\nE"},
+		{name: "after end", text: "#TITLE:a\n#ARTIST:b\n#BPM:100\n: 0 2 5 x\nE\ntrailing text\n"},
+		{name: "missing end", text: "#TITLE:a\n#ARTIST:b\n#BPM:100\n: 0 2 5 x\n"},
+		{name: "line without colon", text: "#TITLE:a\n#ti\n#ARTIST:b\n#BPM:100\n: 0 2 5 x\nE\n"},
+		{name: "duplicate tags", text: "#TITLE:a\n#TITLE:b\n#ARTIST:b\n#BPM:100\n: 0 2 5 x\nE\n"},
+		{name: "invalid notes", text: "#TITLE:a\n#ARTIST:b\n#BPM:100\n: 0 2 5 x\n: x y\n? 2 2 5 y\nE\n"},
+		{name: "encoding", text: "#ENCODING:CP1252\n#TITLE:Caf\xe9\n#ARTIST:b\n#BPM:100\n: 0 2 5 x\nE\n"},
+		{name: "detected encoding", text: "#TITLE:Espa\xf1a\n#ARTIST:b\n#BPM:100\n: 0 2 5 x\nE\n"},
+		{name: "bom", text: "\xef\xbb\xbf#TITLE:a\n#ARTIST:b\n#BPM:100\n: 0 2 5 x\nE\n"},
+		{name: "utf-16", text: "\xff\xfe#\x00T\x00I\x00T\x00L\x00E\x00:\x00a\x00\n\x00:\x00 \x000\x00 \x002\x00 \x005\x00 \x00x\x00\n\x00E\x00\n\x00"},
+		{name: "relative", text: "#TITLE:a\n#ARTIST:b\n#BPM:100\n#RELATIVE:yes\n#MEDLEYSTARTBEAT:2\n: 0 2 5 x\n- 2 4\n: 0 2 5 y\nE\n"},
+		{name: "duet", text: "#TITLE:a\n#ARTIST:b\n#BPM:100\n#P1:c\n#P2:d\nP1\n: 0 2 5 x\nP2\n: 0 2 5 y\nE\n"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			song := readSong(t, tc.text, KeepSyntax())
+			if got := write(t, Writer{}, song); got != tc.text {
+				t.Errorf("got\n%q\nwant\n%q", got, tc.text)
+			}
+		})
+	}
+}
+
+// Editing a song read with KeepSyntax only changes the lines of the edit.
+func TestWriteSyntaxEdits(t *testing.T) {
+	const text = "// comment\n#title:a\n#ARTIST:b\n#BPM:100,5\n#GAP:0\n: 0 2 5 x\n// note comment\n: 2 2 5 y\n- 4\n: 6 2 5 z\nE\n"
+	for _, tc := range []struct {
+		name string
+		edit func(song *Song)
+		want string
+	}{
+		{
+			name: "title",
+			edit: func(song *Song) { song.Title = "c" },
+			want: "// comment\n#title:c\n#ARTIST:b\n#BPM:100,5\n#GAP:0\n: 0 2 5 x\n// note comment\n: 2 2 5 y\n- 4\n: 6 2 5 z\nE\n",
+		},
+		{
+			name: "new tag",
+			edit: func(song *Song) { song.Genre = "Pop" },
+			want: "// comment\n#title:a\n#ARTIST:b\n#BPM:100,5\n#GAP:0\n#GENRE:Pop\n: 0 2 5 x\n// note comment\n: 2 2 5 y\n- 4\n: 6 2 5 z\nE\n",
+		},
+		{
+			name: "removed tag",
+			edit: func(song *Song) { song.Artist = "" },
+			want: "// comment\n#title:a\n#BPM:100,5\n#GAP:0\n: 0 2 5 x\n// note comment\n: 2 2 5 y\n- 4\n: 6 2 5 z\nE\n",
+		},
+		{
+			name: "number",
+			edit: func(song *Song) { song.Gap = 250 },
+			want: "// comment\n#title:a\n#ARTIST:b\n#BPM:100,5\n#GAP:250\n: 0 2 5 x\n// note comment\n: 2 2 5 y\n- 4\n: 6 2 5 z\nE\n",
+		},
+		{
+			name: "pitch",
+			edit: func(song *Song) { song.Tracks[0].Lines[0].Notes[1].Pitch = 7 },
+			want: "// comment\n#title:a\n#ARTIST:b\n#BPM:100,5\n#GAP:0\n: 0 2 5 x\n// note comment\n: 2 2 7 y\n- 4\n: 6 2 5 z\nE\n",
+		},
+		{
+			name: "removed note",
+			edit: func(song *Song) { song.Tracks[0].Lines[0].Notes = song.Tracks[0].Lines[0].Notes[:1] },
+			want: "// comment\n#title:a\n#ARTIST:b\n#BPM:100,5\n#GAP:0\n: 0 2 5 x\n// note comment\n- 4\n: 6 2 5 z\nE\n",
+		},
+		{
+			name: "added note",
+			edit: func(song *Song) {
+				line := &song.Tracks[0].Lines[1]
+				line.Notes = append(line.Notes, Note{Kind: NormalNote, Start: 8, Length: 1, Pitch: 5, Syllable: "w"})
+			},
+			want: "// comment\n#title:a\n#ARTIST:b\n#BPM:100,5\n#GAP:0\n: 0 2 5 x\n// note comment\n: 2 2 5 y\n- 4\n: 6 2 5 z\n: 8 1 5 w\nE\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			song := readSong(t, text, KeepSyntax())
+			tc.edit(&song)
+			if got := write(t, Writer{}, song); got != tc.want {
+				t.Errorf("got\n%q\nwant\n%q", got, tc.want)
+			}
+		})
+	}
+}
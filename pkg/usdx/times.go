@@ -0,0 +1,51 @@
+package usdx
+
+import (
+	"math"
+	"strconv"
+	"time"
+)
+
+// Times holds the tags of a song that describe a point in time or an offset,
+// converted to durations.
+type Times struct {
+	// Gap is the time from the start of the audio file to the first beat.
+	Gap time.Duration
+	// VideoGap is the time from the start of the video to the start of the
+	// audio. It is negative if the video starts later.
+	VideoGap time.Duration
+	// Start and End limit the part of the audio file that is played. End is
+	// 0 if the whole file is played.
+	Start time.Duration
+	End   time.Duration
+	// PreviewStart is where the preview in the song selection starts.
+	PreviewStart time.Duration
+}
+
+// Times returns the time related tags of the song as durations. #GAP and #END
+// are given in milliseconds, the other tags in seconds. Since format version
+// 1.0.0, #GAP is a whole number of milliseconds, so a fraction is rounded.
+func (s Song) Times() Times {
+	gap := exact(s.Gap) / 1000
+	if s.Version.AtLeast(Version100) {
+		gap = math.Round(gap*1000) / 1000
+	}
+	return Times{
+		Gap:          toDuration(gap),
+		VideoGap:     toDuration(exact(s.VideoGap)),
+		Start:        toDuration(exact(s.Start)),
+		End:          time.Duration(s.End) * time.Millisecond,
+		PreviewStart: toDuration(exact(s.PreviewStart)),
+	}
+}
+
+// exact converts a value read from a file to float64 without the rounding
+// errors of float32, so that 0.1 stays 0.1.
+func exact(f float32) float64 {
+	v, _ := strconv.ParseFloat(formatFloat32(f), 64)
+	return v
+}
+
+func toDuration(seconds float64) time.Duration {
+	return time.Duration(math.Round(seconds * float64(time.Second)))
+}
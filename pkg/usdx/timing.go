@@ -0,0 +1,100 @@
+package usdx
+
+import (
+	"sort"
+	"time"
+)
+
+type BPMChange struct {
+	Beat int
+	BPM  float32
+}
+
+// TimingMap converts between beats and the time since the start of the audio
+// file.
+type TimingMap struct {
+	gap      time.Duration
+	segments []timingSegment
+}
+
+type timingSegment struct {
+	beat float64
+	// time of the first beat of this segment, not including the gap
+	start time.Duration
+	// duration of one beat in nanoseconds
+	length float64
+}
+
+// Timing returns the timing map of the song, taking #GAP, #BPM and BPM changes
+// into account. The BPM given in song files is multiplied by four by USDX, so
+// a beat lasts 15 seconds divided by the BPM.
+//
+// For relative songs, the beats passed to the map have to be absolute, see
+// ToAbsolute.
+func (s Song) Timing() TimingMap {
+	changes := append([]BPMChange{{Beat: 0, BPM: s.BPM}}, s.BPMChanges...)
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Beat < changes[j].Beat
+	})
+
+	m := TimingMap{
+		gap: s.Times().Gap,
+	}
+	for _, change := range changes {
+		segment := timingSegment{
+			beat:   float64(change.Beat),
+			length: beatLength(change.BPM),
+		}
+		if n := len(m.segments); n > 0 {
+			previous := m.segments[n-1]
+			segment.start = previous.start + previous.duration(segment.beat-previous.beat)
+			if segment.beat == previous.beat {
+				// a later change at the same beat replaces the earlier one
+				m.segments = m.segments[:n-1]
+			}
+		}
+		m.segments = append(m.segments, segment)
+	}
+	return m
+}
+
+func beatLength(bpm float32) float64 {
+	if bpm <= 0 {
+		return 0
+	}
+	return 15 * float64(time.Second) / float64(bpm)
+}
+
+func (s timingSegment) duration(beats float64) time.Duration {
+	return time.Duration(beats * s.length)
+}
+
+// Time returns the time at which the given beat is reached. Beats before the
+// first beat use the initial BPM.
+func (m TimingMap) Time(beat float64) time.Duration {
+	segment := m.segments[0]
+	for _, s := range m.segments[1:] {
+		if s.beat > beat {
+			break
+		}
+		segment = s
+	}
+	return m.gap + segment.start + segment.duration(beat-segment.beat)
+}
+
+// Beat returns the beat that is reached at the given time. It is the inverse
+// of Time.
+func (m TimingMap) Beat(t time.Duration) float64 {
+	t -= m.gap
+	segment := m.segments[0]
+	for _, s := range m.segments[1:] {
+		if s.start > t {
+			break
+		}
+		segment = s
+	}
+	if segment.length == 0 {
+		return segment.beat
+	}
+	return segment.beat + float64(t-segment.start)/segment.length
+}
@@ -0,0 +1,38 @@
+package usdx
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimingMap(t *testing.T) {
+	// at 300 BPM a beat lasts 50ms, at 150 BPM 100ms
+	for _, tc := range []struct {
+		name    string
+		changes []BPMChange
+		beat    float64
+		time    time.Duration
+	}{
+		{name: "gap", beat: 0, time: time.Second},
+		{name: "constant", beat: 20, time: 2 * time.Second},
+		{name: "fraction", beat: 2.5, time: 1125 * time.Millisecond},
+		{name: "before first beat", beat: -10, time: 500 * time.Millisecond},
+		{name: "before change", changes: []BPMChange{{20, 150}}, beat: 10, time: 1500 * time.Millisecond},
+		{name: "at change", changes: []BPMChange{{20, 150}}, beat: 20, time: 2 * time.Second},
+		{name: "after change", changes: []BPMChange{{20, 150}}, beat: 30, time: 3 * time.Second},
+		{name: "two changes", changes: []BPMChange{{20, 150}, {30, 600}}, beat: 40, time: 3250 * time.Millisecond},
+		{name: "unordered changes", changes: []BPMChange{{30, 600}, {20, 150}}, beat: 40, time: 3250 * time.Millisecond},
+		{name: "same beat", changes: []BPMChange{{20, 600}, {20, 150}}, beat: 30, time: 3 * time.Second},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			song := Song{BPM: 300, Gap: 1000, BPMChanges: tc.changes}
+			m := song.Timing()
+			if got := m.Time(tc.beat); got != tc.time {
+				t.Errorf("Time(%v): got %v, want %v", tc.beat, got, tc.time)
+			}
+			if got := m.Beat(tc.time); got != tc.beat {
+				t.Errorf("Beat(%v): got %v, want %v", tc.time, got, tc.beat)
+			}
+		})
+	}
+}
@@ -2,14 +2,13 @@ package usdx
 
 import (
 	"bufio"
-	"fmt"
 	"io"
+	"sort"
 	"strconv"
 	"strings"
 	"unicode"
 
 	"github.com/Patagonicus/usdx-reader/pkg/encoding"
-	"go.uber.org/zap"
 )
 
 // tags understood by USDX
@@ -42,36 +41,66 @@ var usdxTags = map[string]bool{
 	"DUETSINGERP2":    true,
 	"P1":              true,
 	"P2":              true,
+	"VERSION":         true,
+	"AUDIO":           true,
+	"VOCALS":          true,
+	"INSTRUMENTAL":    true,
+	"TAGS":            true,
+	"PROVIDEDBY":      true,
+	"AUDIOURL":        true,
+	"COVERURL":        true,
+	"VIDEOURL":        true,
+}
+
+// Tags returns the names of the tags understood by USDX, sorted by name.
+func Tags() []string {
+	tags := make([]string, 0, len(usdxTags))
+	for tag := range usdxTags {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
 }
 
 type Reader struct {
 	encodings       map[string]Encoding
 	defaultEncoding Encoding
-	l               *zap.Logger
+	strict          bool
+	listFormats     map[string]ListFormat
+	knownTags       map[string]KnownTag
+	keepSyntax      bool
+	l               Logger
 }
 
-func NewReader(l *zap.Logger) Reader {
-	m := make(map[string]Encoding)
+// NewReader creates a reader. Without options, it understands the encodings
+// Auto, UTF8, CP1250 and CP1252, defaults to Auto and does not log anything.
+func NewReader(opts ...Option) Reader {
+	r := Reader{
+		encodings:       make(map[string]Encoding),
+		defaultEncoding: encoding.Auto,
+		listFormats:     make(map[string]ListFormat),
+		knownTags:       make(map[string]KnownTag),
+		l:               nopLogger{},
+	}
+	for _, tag := range DefaultKnownTags {
+		r.knownTags[tag.Tag] = tag
+	}
+	for tag, format := range DefaultListFormats {
+		r.listFormats[tag] = format
+	}
 	for _, enc := range []Encoding{encoding.Auto, encoding.UTF8, encoding.CP1250, encoding.CP1252} {
-		name := enc.Name()
-		if _, ok := m[name]; ok {
-			l.Warn("duplicate encoding",
-				zap.String("name", name),
-			)
-		}
-		m[name] = enc
+		r.encodings[enc.Name()] = enc
 	}
-	return Reader{
-		encodings:       m,
-		defaultEncoding: encoding.Auto,
-		l:               l,
+	for _, opt := range opts {
+		opt(&r)
 	}
+	return r
 }
 
-func (r Reader) Read(in io.ReadSeeker, dir, sourceFile string) (Song, []error, error) {
-	l := r.l.With(
-		zap.String("dir", dir),
-		zap.String("sourceFile", sourceFile),
+func (r Reader) Read(in io.Reader, dir, sourceFile string) (Song, []Diagnostic, error) {
+	l := withContext(r.l,
+		"dir", dir,
+		"sourceFile", sourceFile,
 	)
 
 	song := Song{
@@ -82,39 +111,158 @@ func (r Reader) Read(in io.ReadSeeker, dir, sourceFile string) (Song, []error, e
 		CalcMedley: true,
 	}
 
-	hasBOM, err := checkBOM(in)
+	br := bufio.NewReader(in)
+	hasBOM, err := checkBOM(br)
 	if err != nil {
-		l.Warn("error detecting byte order mark",
-			zap.Error(err),
+		l.Warnw("error detecting byte order mark",
+			"error", err,
 		)
 		return song, nil, err
 	}
 	if hasBOM {
-		l.Debug("detected BOM, setting encoding to UTF8")
+		l.Debugw("detected BOM, setting encoding to UTF8")
 		song.Encoding = encoding.UTF8
+		song.BOM = true
 	}
 
-	var warnings []error
+	data, err := io.ReadAll(br)
+	if err != nil {
+		return song, nil, err
+	}
+
+	// Auto detects the encoding once for the whole file. UTF-16 is converted
+	// to UTF-8 up front, as lines can not be split before decoding.
+	var diagnostics []Diagnostic
+	var detected Encoding
+	transcoded := false
+	if auto, ok := song.Encoding.(encoding.UTF8DetectingDecoder); ok {
+		detection := auto.Detect(data)
+		song.Detection = &detection
+		detected = detection.Encoding
+		l.Debugw("detected encoding",
+			"encoding", detection.Encoding.Name(),
+			"confidence", detection.Confidence,
+		)
+
+		switch detection.Encoding.Name() {
+		case encoding.UTF16LE.Name(), encoding.UTF16BE.Name():
+			data, err = fromUTF16(data, detection)
+			if err != nil {
+				return song, nil, err
+			}
+			detected = encoding.UTF8
+			transcoded = true
+		}
+	} else if detection, ok := encoding.DetectUTF16(data); ok && !hasBOM {
+		// USDX can not read this, but there is nothing to gain from failing
+		l.Warnw("file is UTF-16, ignoring encoding",
+			"encoding", detection.Encoding.Name(),
+			"ignored", song.Encoding.Name(),
+		)
+		diagnostics = append(diagnostics, warningAt(UTF16, 0, 0, "file is %v, not %v", detection.Encoding.Name(), song.Encoding.Name()))
+		data, err = fromUTF16(data, detection)
+		if err != nil {
+			return song, diagnostics, err
+		}
+		song.Detection = &detection
+		detected = encoding.UTF8
+		transcoded = true
+	}
+	decoderFor := func(enc Encoding) Encoding {
+		if _, ok := enc.(encoding.UTF8DetectingDecoder); (ok || transcoded) && detected != nil {
+			return detected
+		}
+		return enc
+	}
+	// Files of format version 1.0.0 and newer are always UTF-8. #ENCODING and
+	// #RELATIVE were removed and are only reported by checkVersion.
+	versioned := peekVersion(data).AtLeast(Version100)
+	if versioned && !transcoded {
+		l.Debugw("format version 1.0.0 or newer, setting encoding to UTF8")
+		song.Encoding = encoding.UTF8
+	}
+	decoder := decoderFor(song.Encoding)
+
 	seen := make(map[string]bool)
-	scanner := bufio.NewScanner(in)
-	for scanner.Scan() {
-		line := scanner.Text()
+	lines := newLineReader(data)
+	// blank lines are only reported if another tag follows them
+	var blank []int
+	if r.keepSyntax {
+		song.Syntax = &Syntax{}
+	}
+	// keep adds a line to the syntax tree, if there is one
+	keep := func(kind LineKind, text string) *SyntaxLine {
+		if song.Syntax == nil {
+			return &SyntaxLine{}
+		}
+		song.Syntax.Lines = append(song.Syntax.Lines, SyntaxLine{
+			Kind:   kind,
+			Text:   text,
+			Ending: lines.lastEnding,
+		})
+		return &song.Syntax.Lines[len(song.Syntax.Lines)-1]
+	}
+	line, ok := lines.next()
+	for ; ok; line, ok = lines.next() {
+		lineNo := lines.line
+		raw, err := decoder.Decode(line)
+		if err != nil {
+			l.Warnw("error decoding line",
+				"error", err,
+			)
+			return song, diagnostics, err
+		}
+		if trim(line) == "" {
+			blank = append(blank, lineNo)
+			keep(BlankLine, raw)
+			continue
+		}
+		if isComment(line) {
+			diagnostics = append(diagnostics, infoAt(SkippedLine, lineNo, 0, "skipped comment in header"))
+			keep(CommentLine, raw)
+			continue
+		}
+		if indented := strings.TrimLeftFunc(line, unicode.IsSpace); !isTag(line) && isTag(indented) {
+			diagnostics = append(diagnostics, infoAt(IndentedTag, lineNo, 1, "removed whitespace before tag"))
+			line = indented
+		}
 		if !isTag(line) {
 			break
 		}
+		for _, n := range blank {
+			diagnostics = append(diagnostics, infoAt(SkippedLine, n, 0, "skipped blank line in header"))
+		}
+		blank = nil
 
-		tag, value, err := getTagAndValue(line, song.Encoding)
+		tag, value, err := getTagAndValue(line, decoder)
 		if err != nil {
-			l.Warn("error decoding line",
-				zap.Error(err),
+			l.Warnw("error decoding line",
+				"error", err,
 			)
-			return song, warnings, err
+			return song, diagnostics, err
 		}
+		// USDX ignores the case of tags. Unknown tags keep their case, so
+		// they are written as they were read.
+		if upper := strings.ToUpper(tag); upper != tag {
+			if _, known := r.knownTags[upper]; known || usdxTags[upper] {
+				tag = upper
+			}
+		}
+		column := valueColumn(line)
+		syntaxLine := keep(TagLine, raw)
+		syntaxLine.Tag, syntaxLine.Value = tag, value
 
 		if seen[tag] && usdxTags[tag] {
-			warnings = append(warnings, fmt.Errorf("duplicate tag '%v'", tag))
+			diagnostics = append(diagnostics, warningAt(DuplicateTag, lineNo, 1, "duplicate tag '%v'", tag))
 		}
 		seen[tag] = true
+		song.Header = append(song.Header, HeaderTag{
+			Tag:    tag,
+			Value:  value,
+			Raw:    raw,
+			Line:   lineNo,
+			Column: column,
+		})
 		switch tag {
 		case "DUETSINGERP1":
 			seen["P1"] = true
@@ -128,6 +276,8 @@ func (r Reader) Read(in io.ReadSeeker, dir, sourceFile string) (Song, []error, e
 
 		err = nil
 		switch tag {
+		case "VERSION":
+			song.Version, err = ParseVersion(value)
 		case "TITLE":
 			song.Title = value
 		case "ARTIST":
@@ -169,37 +319,31 @@ func (r Reader) Read(in io.ReadSeeker, dir, sourceFile string) (Song, []error, e
 		case "NOTESGAP":
 			song.NotesGap, err = parseInt(value)
 		case "RELATIVE":
-			if strings.ToUpper(value) == "YES" {
+			if strings.ToUpper(value) == "YES" && !versioned {
 				song.Relative = true
 			}
 		case "ENCODING":
 			enc, ok := r.encodings[value]
-			if ok {
+			if versioned {
+				l.Debugw("ignoring encoding in versioned file",
+					"name", value,
+				)
+			} else if ok {
 				song.Encoding = enc
+				decoder = decoderFor(enc)
 			} else {
-				l.Warn("unknown encoding, keeping current on",
-					zap.String("name", value),
-					zap.String("current encoding", song.Encoding.Name()),
+				l.Warnw("unknown encoding, keeping current on",
+					"name", value,
+					"current encoding", song.Encoding.Name(),
 				)
+				diagnostics = append(diagnostics, warningAt(UnknownEncoding, lineNo, column, "unknown encoding '%v', keeping %v", value, song.Encoding.Name()))
 			}
 		case "PREVIEWSTART":
 			song.PreviewStart, err = parseFloat32I18n(value)
 		case "MEDLEYSTARTBEAT":
-			if song.Relative {
-				l.Warn("ignoring medley start beat because relative is set",
-					zap.String("value", value),
-				)
-			} else {
-				song.MedleyStartBeat, err = parseInt(value)
-			}
+			song.MedleyStartBeat, err = parseInt(value)
 		case "MEDLEYENDBEAT":
-			if song.Relative {
-				l.Warn("ignoring medley end beat because relative is set",
-					zap.String("value", value),
-				)
-			} else {
-				song.MedleyEndBeat, err = parseInt(value)
-			}
+			song.MedleyEndBeat, err = parseInt(value)
 		case "CALCMEDLEY":
 			if strings.ToUpper(value) == "OFF" {
 				song.CalcMedley = false
@@ -208,39 +352,147 @@ func (r Reader) Read(in io.ReadSeeker, dir, sourceFile string) (Song, []error, e
 			song.DuetSingerP1 = value
 		case "DUETSINGERP2", "P2":
 			song.DuetSingerP2 = value
+		case "AUDIO":
+			song.Audio = value
+		case "VOCALS":
+			song.Vocals = value
+		case "INSTRUMENTAL":
+			song.Instrumental = value
+		case "TAGS":
+			song.Tags = value
+		case "PROVIDEDBY":
+			song.ProvidedBy = value
+		case "AUDIOURL":
+			song.AudioURL = value
+		case "COVERURL":
+			song.CoverURL = value
+		case "VIDEOURL":
+			song.VideoURL = value
 		default:
-			l.Warn("unknown tag",
-				zap.String("tag", tag),
-				zap.String("value", value),
-			)
+			if known, ok := r.knownTags[tag]; ok {
+				l.Debugw("known tag",
+					"tag", tag,
+					"value", value,
+				)
+				if known.Parse != nil {
+					err = known.Parse(&song, value)
+				}
+			} else {
+				l.Warnw("unknown tag",
+					"tag", tag,
+					"value", value,
+				)
+				diagnostics = append(diagnostics, warningAt(UnknownTag, lineNo, 1, "unknown tag '%v'", tag))
+			}
 			song.CustomTags = append(song.CustomTags, Tag{
 				Tag:     tag,
 				Content: value,
 			})
 		}
 		if err != nil {
-			l.Warn("failed to parse tag",
-				zap.String("tag", tag),
-				zap.String("value", value),
-				zap.Error(err),
+			l.Warnw("failed to parse tag",
+				"tag", tag,
+				"value", value,
+				"error", err,
+			)
+			code := BadNumber
+			if tag == "VERSION" {
+				code = BadVersion
+			}
+			diagnostics = append(diagnostics, warningAt(code, lineNo, column, "error adding tag '%v': %v", tag, err.Error()))
+		}
+	}
+	if song.Relative {
+		// the beats are kept, so that ToAbsolute can resolve them
+		for _, tag := range []string{"MEDLEYSTARTBEAT", "MEDLEYENDBEAT"} {
+			if t, ok := song.LookupTag(tag); ok {
+				l.Warnw("USDX ignores medley beats of relative songs",
+					"tag", tag,
+					"value", t.Value,
+				)
+				diagnostics = append(diagnostics, warningAt(IgnoredTag, t.Line, 1, "USDX ignores #%v in relative songs", tag))
+			}
+		}
+	}
+	diagnostics = append(diagnostics, checkVersion(song.Version, song.Header)...)
+	song.splitLists(r.listFormats)
+
+	// line is the first line after the header, if there is one
+	notes := newNotesParser(&song)
+	for ; ok; line, ok = lines.next() {
+		line, err := decoder.Decode(line)
+		if err != nil {
+			l.Warnw("error decoding line",
+				"error", err,
 			)
-			warnings = append(warnings, fmt.Errorf("error adding tag '%v': %v", tag, err.Error()))
+			return song, diagnostics, err
+		}
+
+		more := notes.parse(lines.line, line)
+		kind := InvalidLine
+		switch {
+		case notes.canonical == "E":
+			kind = EndLine
+		case notes.canonical != "":
+			kind = NoteLine
+		case trim(line) == "":
+			kind = BlankLine
+		}
+		keep(kind, line).canonical = notes.canonical
+		if !more {
+			break
+		}
+	}
+	if song.Syntax != nil && ok {
+		for line, ok = lines.next(); ok; line, ok = lines.next() {
+			line, err := decoder.Decode(line)
+			if err != nil {
+				return song, diagnostics, err
+			}
+			keep(TrailingLine, line)
+		}
+	}
+	song.LineEnding = lines.ending
+	diagnostics = append(diagnostics, lines.diagnostics...)
+	diagnostics = append(diagnostics, notes.finish()...)
+	if _, auto := song.Encoding.(encoding.UTF8DetectingDecoder); auto && song.Detection != nil && len(song.Detection.Ambiguous) > 0 {
+		var names []string
+		for _, enc := range song.Detection.Ambiguous {
+			names = append(names, enc.Name())
 		}
+		diagnostics = append(diagnostics, warningAt(AmbiguousEncoding, 0, 0, "encoding could also be %v, using %v; add an #ENCODING tag to choose", strings.Join(names, " or "), song.Detection.Encoding.Name()))
 	}
-	if scanner.Err() != nil {
-		return song, warnings, scanner.Err()
+	if song.Syntax != nil {
+		song.Syntax.tags = song.headerTags()
+		song.Syntax.notes = song.NoteLines()
+		song.Syntax.encoding = song.SourceEncoding()
+		song.Syntax.version = song.Version
 	}
-	song.Notes = append(song.Notes, scanner.Text())
-	for scanner.Scan() {
-		song.Notes = append(song.Notes, scanner.Text())
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		return diagnostics[i].Line < diagnostics[j].Line
+	})
+	if r.strict {
+		for _, d := range diagnostics {
+			if d.Severity >= SeverityWarning {
+				return song, diagnostics, d
+			}
+		}
 	}
-	return song, warnings, scanner.Err()
+	return song, diagnostics, nil
 }
 
 func isTag(line string) bool {
 	return strings.HasPrefix(line, "#")
 }
 
+// valueColumn returns the column of the value in a tag line.
+func valueColumn(line string) int {
+	if sep := strings.IndexRune(line, ':'); sep >= 0 {
+		return sep + 2
+	}
+	return len(line) - len(strings.TrimLeft(line, "#")) + 1
+}
+
 func getTagAndValue(line string, decoder Encoding) (string, string, error) {
 	line = strings.TrimLeft(line, "#")
 
@@ -273,22 +525,33 @@ func trim(s string) string {
 	})
 }
 
-func checkBOM(r io.ReadSeeker) (bool, error) {
-	var bom [3]byte
-	_, err := io.ReadFull(r, bom[:])
+func fromUTF16(data []byte, detection encoding.Detection) ([]byte, error) {
+	if detection.BOM {
+		data = data[2:]
+	}
+	return detection.Encoding.Decoder.Bytes(data)
+}
+
+func checkBOM(r *bufio.Reader) (bool, error) {
+	bom, err := r.Peek(3)
+	if err == io.EOF {
+		// too short to have a BOM
+		return false, nil
+	}
 	if err != nil {
 		return false, err
 	}
 	if bom[0] == 0xef && bom[1] == 0xbb && bom[2] == 0xbf {
-		return true, nil
+		_, err = r.Discard(3)
+		return true, err
 	}
-	_, err = r.Seek(0, io.SeekStart)
-	return false, err
+	return false, nil
 }
 
 type Song struct {
 	Dir             string
 	SourceFile      string
+	Version         Version
 	Title           string
 	Artist          string
 	SoundFile       string
@@ -315,8 +578,72 @@ type Song struct {
 	CalcMedley      bool
 	DuetSingerP1    string
 	DuetSingerP2    string
+	Audio           string
+	Vocals          string
+	Instrumental    string
+	Tags            string
+	ProvidedBy      string
+	AudioURL        string
+	CoverURL        string
+	VideoURL        string
 	CustomTags      []Tag
-	Notes           []string
+	Tracks          []Track
+
+	// Album, Comment, Author, ID, DuetSingerP3 and Rating are filled from
+	// the known tags of other games, see DefaultKnownTags. The tags are kept
+	// in CustomTags, which is what the writer uses.
+	Album        string
+	Comment      string
+	Author       string
+	ID           string
+	DuetSingerP3 string
+	Rating       float32
+
+	// Genres, Languages, Editions and TagList hold the values of #GENRE,
+	// #LANGUAGE, #EDITION and #TAGS, split as configured with
+	// WithListFormat. The writer only uses the unsplit values.
+	Genres    []string
+	Languages []string
+	Editions  []string
+	TagList   []string
+
+	// BPMChanges holds the B lines of the note section. Their beats are
+	// always absolute, even for relative songs.
+	BPMChanges []BPMChange
+	// Terminated is set if the note section ends with an E line.
+	Terminated bool
+	// BOM is set if the file started with a UTF-8 byte order mark.
+	BOM bool
+	// LineEnding is the line ending used by the file, "\n", "\r\n" or "\r".
+	LineEnding string
+	// Header holds the tags of the file in the order they were read,
+	// including duplicates. It is empty for songs that were not read from a
+	// file.
+	Header []HeaderTag
+	// Syntax holds all lines of the file if the reader was created with
+	// KeepSyntax.
+	Syntax *Syntax
+	// Detection is the result of detecting the encoding of the file. It is
+	// only set if the encoding was Auto.
+	Detection *encoding.Detection
+}
+
+// SourceEncoding returns the encoding the song was read with. For Auto, this
+// is the detected encoding.
+func (s Song) SourceEncoding() Encoding {
+	if _, ok := s.Encoding.(encoding.UTF8DetectingDecoder); ok && s.Detection != nil {
+		return s.Detection.Encoding
+	}
+	return s.Encoding
+}
+
+// AudioFile returns the audio file of the song, which is set by #AUDIO since
+// format version 1.1.0 and by #MP3 before.
+func (s Song) AudioFile() string {
+	if s.Audio != "" {
+		return s.Audio
+	}
+	return s.SoundFile
 }
 
 type Tag struct {
@@ -327,4 +654,5 @@ type Tag struct {
 type Encoding interface {
 	Name() string
 	Decode(s string) (string, error)
+	Encode(s string) (string, error)
 }
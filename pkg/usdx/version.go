@@ -0,0 +1,189 @@
+package usdx
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Version is a version of the UltraStar file format as given by #VERSION. The
+// zero value stands for legacy files without a version.
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+}
+
+var (
+	Legacy     = Version{}
+	Version100 = Version{1, 0, 0}
+	Version110 = Version{1, 1, 0}
+	Version120 = Version{1, 2, 0}
+
+	// LatestVersion is the newest format version known to this package.
+	LatestVersion = Version120
+)
+
+// ParseVersion parses a version of the form MAJOR.MINOR.PATCH. Minor and patch
+// may be omitted.
+func ParseVersion(s string) (Version, error) {
+	var v Version
+	parts := strings.Split(trim(s), ".")
+	if len(parts) > 3 {
+		return v, fmt.Errorf("invalid version '%v'", s)
+	}
+	for i, field := range []*int{&v.Major, &v.Minor, &v.Patch}[:len(parts)] {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("invalid version '%v'", s)
+		}
+		*field = n
+	}
+	return v, nil
+}
+
+func (v Version) String() string {
+	if v.IsLegacy() {
+		return "legacy"
+	}
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+func (v Version) IsLegacy() bool {
+	return v == Legacy
+}
+
+// Compare returns -1, 0 or 1 if v is older than, equal to or newer than o.
+func (v Version) Compare(o Version) int {
+	for _, d := range []int{v.Major - o.Major, v.Minor - o.Minor, v.Patch - o.Patch} {
+		switch {
+		case d < 0:
+			return -1
+		case d > 0:
+			return 1
+		}
+	}
+	return 0
+}
+
+// AtLeast reports whether v is o or newer.
+func (v Version) AtLeast(o Version) bool {
+	return v.Compare(o) >= 0
+}
+
+// peekVersion returns the format version given in the header of data, before
+// the file is decoded. Files without a valid #VERSION tag are legacy files.
+func peekVersion(data []byte) Version {
+	var version Version
+	lines := newLineReader(data)
+	for line, ok := lines.next(); ok; line, ok = lines.next() {
+		line = strings.TrimLeftFunc(line, unicode.IsSpace)
+		if line == "" || isComment(line) {
+			continue
+		}
+		if !isTag(line) {
+			break
+		}
+		line = strings.TrimLeft(line, "#")
+		sep := strings.IndexRune(line, ':')
+		if sep < 0 || strings.ToUpper(line[:sep]) != "VERSION" {
+			continue
+		}
+		if v, err := ParseVersion(line[sep+1:]); err == nil {
+			version = v
+		}
+	}
+	return version
+}
+
+// tagVersion describes in which format versions a tag may be used. Zero values
+// mean that the tag was always there or has not been deprecated or removed.
+type tagVersion struct {
+	added      Version
+	deprecated Version
+	removed    Version
+	// replacement is the tag to use instead of a deprecated or removed one
+	replacement string
+}
+
+var tagVersions = map[string]tagVersion{
+	"VERSION":      {added: Version100},
+	"RELATIVE":     {removed: Version100},
+	"ENCODING":     {removed: Version100},
+	"RESOLUTION":   {removed: Version100},
+	"NOTESGAP":     {removed: Version100},
+	"DUETSINGERP1": {deprecated: Version100, replacement: "P1"},
+	"DUETSINGERP2": {deprecated: Version100, replacement: "P2"},
+	"MP3":          {deprecated: Version110, replacement: "AUDIO"},
+	"AUDIO":        {added: Version110},
+	"VOCALS":       {added: Version110},
+	"INSTRUMENTAL": {added: Version110},
+	"TAGS":         {added: Version110},
+	"PROVIDEDBY":   {added: Version110},
+	"AUDIOURL":     {added: Version120},
+	"COVERURL":     {added: Version120},
+	"VIDEOURL":     {added: Version120},
+}
+
+// mandatoryTags returns the tags that files of the given version have to
+// contain. Alternatives are separated by a pipe. Legacy files are not checked.
+func mandatoryTags(v Version) []string {
+	switch {
+	case v.IsLegacy():
+		return nil
+	case v.AtLeast(Version110):
+		return []string{"VERSION", "TITLE", "ARTIST", "AUDIO|MP3", "BPM"}
+	default:
+		return []string{"VERSION", "TITLE", "ARTIST", "MP3", "BPM"}
+	}
+}
+
+// checkVersion validates the tags of a song against its format version. header
+// contains the tags in the order they were read.
+func checkVersion(v Version, header []HeaderTag) []Diagnostic {
+	var diagnostics []Diagnostic
+	if v.Major > LatestVersion.Major {
+		diagnostics = append(diagnostics, warningAt(BadVersion, 0, 0, "unsupported format version %v", v))
+	}
+
+	seen := make(map[string]bool)
+	for _, tag := range header {
+		seen[tag.Tag] = true
+
+		info := tagVersions[tag.Tag]
+		switch {
+		case !v.AtLeast(info.added):
+			diagnostics = append(diagnostics, warningAt(UnsupportedTag, tag.Line, 1, "tag '%v' requires format version %v", tag.Tag, info.added))
+		case !info.removed.IsLegacy() && v.AtLeast(info.removed):
+			diagnostics = append(diagnostics, warningAt(RemovedTag, tag.Line, 1, "tag '%v' was removed in format version %v", tag.Tag, info.removed))
+		case !info.deprecated.IsLegacy() && v.AtLeast(info.deprecated):
+			diagnostics = append(diagnostics, warningAt(DeprecatedTag, tag.Line, 1, "tag '%v' is deprecated since format version %v, use '%v'", tag.Tag, info.deprecated, info.replacement))
+		}
+
+		if v.AtLeast(Version100) {
+			switch tag.Tag {
+			case "BPM", "GAP", "VIDEOGAP", "START", "PREVIEWSTART":
+				if i := strings.Index(tag.Value, ","); i >= 0 {
+					diagnostics = append(diagnostics, warningAt(DecimalComma, tag.Line, tag.Column+i, "tag '%v' uses a decimal comma, which is not allowed in format version %v", tag.Tag, v))
+				}
+			}
+			if tag.Tag == "GAP" {
+				if _, err := parseInt(tag.Value); err != nil {
+					diagnostics = append(diagnostics, warningAt(BadNumber, tag.Line, tag.Column, "tag 'GAP' must be a whole number of milliseconds in format version %v", v))
+				}
+			}
+		}
+	}
+
+mandatory:
+	for _, alternatives := range mandatoryTags(v) {
+		for _, tag := range strings.Split(alternatives, "|") {
+			if seen[tag] {
+				continue mandatory
+			}
+		}
+		diagnostics = append(diagnostics, warningAt(MissingTag, 0, 0, "missing mandatory tag '%v'", strings.Split(alternatives, "|")[0]))
+	}
+	return diagnostics
+}
@@ -0,0 +1,65 @@
+package usdx
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Patagonicus/usdx-reader/pkg/encoding"
+)
+
+func TestPeekVersion(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		text string
+		want Version
+	}{
+		{name: "legacy", text: "#TITLE:Song\n: 0 1 0 a\n", want: Version{}},
+		{name: "version", text: "#TITLE:Song\n#VERSION:1.1.0\n", want: Version110},
+		{name: "lower case", text: "#version:1.0.0\n", want: Version100},
+		{name: "comment and indentation", text: "// comment\n\n  #VERSION:1.0.0\n", want: Version100},
+		{name: "after notes", text: "#TITLE:Song\n: 0 1 0 a\n#VERSION:1.0.0\n", want: Version{}},
+		{name: "invalid", text: "#VERSION:one\n", want: Version{}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := peekVersion([]byte(tc.text)); got != tc.want {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestReadVersionedIgnoresRemovedTags(t *testing.T) {
+	text := "#VERSION:1.0.0\n#TITLE:Caf\xc3\xa9\n#ENCODING:CP1252\n#RELATIVE:yes\n: 0 1 0 a\n- 2 0\n: 0 1 0 b\nE\n"
+	song, diagnostics, err := NewReader().Read(strings.NewReader(text), "", "test.txt")
+	if err != nil {
+		t.Fatalf("error reading song: %v", err)
+	}
+	if song.Title != "Café" {
+		t.Errorf("got title %q, want %q", song.Title, "Café")
+	}
+	if song.Encoding != encoding.UTF8 {
+		t.Errorf("got encoding %v, want UTF8", song.Encoding.Name())
+	}
+	if song.Relative {
+		t.Error("song is relative")
+	}
+	removed := 0
+	for _, d := range diagnostics {
+		if d.Code == RemovedTag {
+			removed++
+		}
+	}
+	if removed != 2 {
+		t.Errorf("got %v removed tags, want 2: %v", removed, diagnostics)
+	}
+}
+
+func TestReadLegacyEncoding(t *testing.T) {
+	song := readSong(t, "#TITLE:Caf\xe9\n#ENCODING:CP1252\n#RELATIVE:yes\n: 0 1 0 a\nE\n")
+	if song.Title != "Café" {
+		t.Errorf("got title %q, want %q", song.Title, "Café")
+	}
+	if !song.Relative {
+		t.Error("song is not relative")
+	}
+}
@@ -0,0 +1,249 @@
+package usdx
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/Patagonicus/usdx-reader/pkg/encoding"
+)
+
+// Writer writes songs in the UltraStar file format.
+type Writer struct {
+	// Encoding is the encoding of the written file. If it is nil, the
+	// encoding of the song is used. Unless the encoding is Auto, the one
+	// detected when reading the song, or a BOM is written, an #ENCODING tag is
+	// added.
+	Encoding Encoding
+	// BOM adds a UTF-8 byte order mark. It can only be used with UTF8. If
+	// Encoding is nil, a BOM is also written if the song was read from a file
+	// with one.
+	BOM bool
+	// LineEnding is used to terminate lines. If it is empty, the line ending
+	// of the song is kept, falling back to "\r\n".
+	LineEnding string
+}
+
+// Write writes the song to out. If the song has a Syntax, only lines that
+// changed are written anew, and a nil Encoding keeps the encoding the file was
+// read with, including #ENCODING tags unless the song was converted between a
+// legacy file and format version 1.0.0 or newer. Otherwise, the song is
+// written in a canonical form.
+func (w Writer) Write(out io.Writer, song Song) error {
+	enc := w.Encoding
+	bom := w.BOM
+	if enc == nil {
+		enc = song.Encoding
+		if song.Syntax != nil {
+			// keep unchanged lines as they were
+			enc = song.SourceEncoding()
+		}
+		bom = bom || song.BOM
+	}
+	if enc == nil {
+		enc = encoding.Auto
+	}
+	if bom && enc.Name() != encoding.UTF8.Name() {
+		return fmt.Errorf("can not write a byte order mark with encoding '%v'", enc.Name())
+	}
+	if song.Version.AtLeast(Version100) {
+		if enc.Name() != encoding.UTF8.Name() && enc.Name() != encoding.Auto.Name() {
+			return fmt.Errorf("format version %v requires UTF-8, can not use encoding '%v'", song.Version, enc.Name())
+		}
+		if song.Relative {
+			return fmt.Errorf("format version %v does not support relative songs", song.Version)
+		}
+	}
+
+	lineEnding := w.LineEnding
+	if lineEnding == "" {
+		lineEnding = song.LineEnding
+	}
+	if lineEnding == "" {
+		lineEnding = "\r\n"
+	}
+
+	buf := bufio.NewWriter(out)
+	if bom {
+		if _, err := buf.Write([]byte{0xef, 0xbb, 0xbf}); err != nil {
+			return err
+		}
+	}
+
+	// detection finds the encoding again, so it does not need a tag
+	_, auto := song.Encoding.(encoding.UTF8DetectingDecoder)
+	detected := auto && song.Detection != nil && enc.Name() == song.Detection.Encoding.Name()
+
+	var tags []Tag
+	if !bom && !detected && enc.Name() != encoding.Auto.Name() && !song.Version.AtLeast(Version100) {
+		tags = append(tags, Tag{"ENCODING", enc.Name()})
+	}
+	if !bom && song.Detection != nil && song.Detection.BOM && enc.Name() == song.Detection.Encoding.Name() {
+		// UTF-16 byte order mark
+		mark, err := enc.Encode("\ufeff")
+		if err != nil {
+			return err
+		}
+		if _, err := buf.WriteString(mark); err != nil {
+			return err
+		}
+	}
+
+	if song.Syntax != nil {
+		lines := &lineWriter{
+			enc:        enc,
+			lineEnding: lineEnding,
+			override:   w.LineEnding,
+		}
+		// #ENCODING tags are rewritten if the encoding changed or if the
+		// format version changed whether they are used
+		var encodingTag *Tag
+		versioned := song.Version.AtLeast(Version100)
+		if enc.Name() != song.Syntax.encoding.Name() || versioned != song.Syntax.version.AtLeast(Version100) {
+			encodingTag = &Tag{Tag: "ENCODING"}
+			if len(tags) > 0 {
+				encodingTag = &tags[0]
+			}
+		}
+		if err := song.writeSyntax(lines, song.headerTags(), encodingTag); err != nil {
+			return err
+		}
+		for _, line := range lines.lines {
+			if _, err := buf.WriteString(line); err != nil {
+				return err
+			}
+		}
+		return buf.Flush()
+	}
+
+	tags = append(tags, song.headerTags()...)
+	for _, tag := range tags {
+		// whole lines are encoded, as UTF-16 encodes the tag and line ending too
+		encoded, err := enc.Encode(formatTag(tag.Tag, tag.Content) + lineEnding)
+		if err != nil {
+			return fmt.Errorf("error encoding tag '%v': %v", tag.Tag, err)
+		}
+		if _, err := buf.WriteString(encoded); err != nil {
+			return err
+		}
+	}
+
+	for _, line := range song.NoteLines() {
+		encoded, err := enc.Encode(line + lineEnding)
+		if err != nil {
+			return fmt.Errorf("error encoding line '%v': %v", line, err)
+		}
+		if _, err := buf.WriteString(encoded); err != nil {
+			return err
+		}
+	}
+
+	return buf.Flush()
+}
+
+// headerTags returns the tags describing the song in the order they are
+// written, omitting tags that have their default value. Numbers are written
+// even if they are 0 if the file they were read from had the tag. Tags are
+// named as required by the format version of the song.
+func (s Song) headerTags() []Tag {
+	var tags []Tag
+	legacy := !s.Version.AtLeast(Version100)
+	add := func(tag, value string) {
+		if value != "" {
+			tags = append(tags, Tag{tag, value})
+		}
+	}
+	// explicit writes a tag that was present in the header with a value of 0
+	// or an empty value
+	explicit := func(tag string) {
+		t, ok := s.LookupTag(tag)
+		if !ok {
+			return
+		}
+		if trim(t.Value) == "" {
+			tags = append(tags, Tag{tag, ""})
+		} else if f, err := parseFloat32I18n(t.Value); err == nil && f == 0 {
+			tags = append(tags, Tag{tag, "0"})
+		}
+	}
+	addFloat := func(tag string, value float32) {
+		if value != 0 {
+			add(tag, formatFloat32(value))
+		} else {
+			explicit(tag)
+		}
+	}
+	addInt := func(tag string, value int) {
+		if value != 0 {
+			add(tag, strconv.Itoa(value))
+		} else {
+			explicit(tag)
+		}
+	}
+
+	if !legacy {
+		add("VERSION", s.Version.String())
+	}
+	add("TITLE", s.Title)
+	add("ARTIST", s.Artist)
+	add("MP3", s.SoundFile)
+	add("AUDIO", s.Audio)
+	add("VOCALS", s.Vocals)
+	add("INSTRUMENTAL", s.Instrumental)
+	add("COVER", s.CoverPath)
+	add("BACKGROUND", s.BackgroundPath)
+	add("VIDEO", s.VideoPath)
+	addFloat("VIDEOGAP", s.VideoGap)
+	add("GENRE", s.Genre)
+	add("EDITION", s.Edition)
+	add("CREATOR", s.Creator)
+	add("LANGUAGE", s.Language)
+	add("TAGS", s.Tags)
+	addInt("YEAR", s.Year)
+	addFloat("BPM", s.BPM)
+	addFloat("GAP", s.Gap)
+	addFloat("START", s.Start)
+	addInt("END", s.End)
+	if legacy && s.Resolution != 4 {
+		add("RESOLUTION", strconv.Itoa(s.Resolution))
+	}
+	if legacy {
+		addInt("NOTESGAP", s.NotesGap)
+	}
+	if s.Relative {
+		add("RELATIVE", "YES")
+	}
+	addFloat("PREVIEWSTART", s.PreviewStart)
+	addInt("MEDLEYSTARTBEAT", s.MedleyStartBeat)
+	addInt("MEDLEYENDBEAT", s.MedleyEndBeat)
+	if !s.CalcMedley {
+		add("CALCMEDLEY", "OFF")
+	}
+	if legacy {
+		add("DUETSINGERP1", s.DuetSingerP1)
+		add("DUETSINGERP2", s.DuetSingerP2)
+	} else {
+		add("P1", s.DuetSingerP1)
+		add("P2", s.DuetSingerP2)
+	}
+	add("PROVIDEDBY", s.ProvidedBy)
+	add("AUDIOURL", s.AudioURL)
+	add("COVERURL", s.CoverURL)
+	add("VIDEOURL", s.VideoURL)
+
+	return append(tags, s.CustomTags...)
+}
+
+// formatTag returns the line of a tag. Header lines without a colon are read
+// as a tag without a name, so they are written without a colon again.
+func formatTag(tag, value string) string {
+	if tag == "" {
+		return "#" + value
+	}
+	return "#" + tag + ":" + value
+}
+
+func formatFloat32(f float32) string {
+	return strconv.FormatFloat(float64(f), 'f', -1, 32)
+}
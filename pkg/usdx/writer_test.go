@@ -0,0 +1,113 @@
+package usdx
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/Patagonicus/usdx-reader/pkg/encoding"
+)
+
+func write(t *testing.T, w Writer, song Song) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := w.Write(&buf, song); err != nil {
+		t.Fatalf("error writing song: %v", err)
+	}
+	return buf.String()
+}
+
+func TestWriterCanonical(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		text   string
+		writer Writer
+		want   string
+	}{
+		{
+			name: "tag order and numbers",
+			text: "#ARTIST:b\n#BPM:300,5\n#TITLE:a\n#GAP:1000.0\n: 0 2 5 x\nE\n",
+			want: "#TITLE:a\n#ARTIST:b\n#BPM:300.5\n#GAP:1000\n: 0 2 5 x\nE\n",
+		},
+		{
+			name: "explicit zero and custom tags",
+			text: "#TITLE:a\n#ARTIST:b\n#BPM:100\n#GAP:0\n#MYTAG:x\n: 0 2 5 x\nE\n",
+			want: "#TITLE:a\n#ARTIST:b\n#BPM:100\n#GAP:0\n#MYTAG:x\n: 0 2 5 x\nE\n",
+		},
+		{
+			name: "line without colon",
+			text: "#TITLE:a\n#ARTIST:b\n#ti\n#BPM:100\n: 0 2 5 x\nE\n",
+			want: "#TITLE:a\n#ARTIST:b\n#BPM:100\n#ti\n: 0 2 5 x\nE\n",
+		},
+		{
+			name: "line endings",
+			text: "#TITLE:a\r\n#ARTIST:b\n#BPM:100\r\n: 0 2 5 x\nE",
+			want: "#TITLE:a\r\n#ARTIST:b\r\n#BPM:100\r\n: 0 2 5 x\r\nE\r\n",
+		},
+		{
+			name:   "encoding",
+			text:   "#TITLE:Café\n#ARTIST:b\n#BPM:100\n: 0 2 5 x\nE\n",
+			writer: Writer{Encoding: encoding.CP1252},
+			want:   "#ENCODING:CP1252\n#TITLE:Caf\xe9\n#ARTIST:b\n#BPM:100\n: 0 2 5 x\nE\n",
+		},
+		{
+			name:   "bom",
+			text:   "#TITLE:a\n#ARTIST:b\n#BPM:100\n: 0 2 5 x\nE\n",
+			writer: Writer{Encoding: encoding.UTF8, BOM: true},
+			want:   "\xef\xbb\xbf#TITLE:a\n#ARTIST:b\n#BPM:100\n: 0 2 5 x\nE\n",
+		},
+		{
+			name:   "detected encoding",
+			text:   "#ARTIST:b\n#TITLE:Espa\xf1a\n#BPM:100\n: 0 2 5 x\nE\n",
+			writer: Writer{Encoding: encoding.CP1252},
+			want:   "#TITLE:Espa\xf1a\n#ARTIST:b\n#BPM:100\n: 0 2 5 x\nE\n",
+		},
+		{
+			name:   "utf-16",
+			text:   "\xff\xfe#\x00T\x00I\x00T\x00L\x00E\x00:\x00a\x00\n\x00E\x00\n\x00",
+			writer: Writer{Encoding: encoding.UTF16LE},
+			want:   "\xff\xfe#\x00T\x00I\x00T\x00L\x00E\x00:\x00a\x00\n\x00E\x00\n\x00",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			song := readSong(t, tc.text)
+			if got := write(t, tc.writer, song); got != tc.want {
+				t.Errorf("got\n%q\nwant\n%q", got, tc.want)
+			}
+		})
+	}
+}
+
+// Reading a written song gives the same song.
+func TestWriterRoundTrip(t *testing.T) {
+	for _, text := range []string{
+		"#TITLE:a\n#ARTIST:b\n#BPM:100\n#GAP:12,5\n#ti\n: 0 2 5 x\n*  2 2 5  y\n- 4\nF 6 2 0 z\nB 8 200\nE\n",
+		"#TITLE:a\n#ARTIST:b\n#BPM:100\n#RELATIVE:yes\n: 0 2 5 x\n- 4 6\n: 0 2 5 y\nE\n",
+		"#TITLE:a\n#ARTIST:b\n#BPM:100\n: 0 2 5 x\n- 4 6\n: 6 2 5 y\nE\n",
+		"#TITLE:a\n#ARTIST:b\n#BPM:100\n#P1:x\n#P2:y\nP1\n: 0 2 5 x\nP2\n: 0 2 5 y\nE\n",
+		"#VERSION:1.1.0\n#TITLE:a\n#ARTIST:b\n#AUDIO:a.mp3\n#BPM:100\n#TAGS:a, b\n: 0 2 5 x\nE\n",
+	} {
+		song := readSong(t, text)
+		again := readSong(t, write(t, Writer{}, song))
+		// the header and line numbers describe the file, not the song
+		song.Header, again.Header = nil, nil
+		clearLines(&song)
+		clearLines(&again)
+		if !reflect.DeepEqual(song, again) {
+			t.Errorf("song changed when writing\n%v\ngot  %+v\nwant %+v", text, again, song)
+		}
+	}
+}
+
+func clearLines(song *Song) {
+	for _, track := range song.Tracks {
+		for _, line := range track.Lines {
+			for i := range line.Notes {
+				line.Notes[i].Line = 0
+			}
+			if line.Break != nil {
+				line.Break.Line = 0
+			}
+		}
+	}
+}